@@ -0,0 +1,137 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Acked is a value returned by an AckSource. It has to be acknowledged using
+// Ack once it has been processed.
+type Acked struct {
+	Value  interface{}
+	Offset int64
+
+	tracker *ackTracker
+}
+
+// Ack marks the value as processed. Acknowledging a value more than once has
+// no effect. It returns an error if the checkpoint could not be saved.
+func (a *Acked) Ack() error {
+	return a.tracker.ack(a.Offset)
+}
+
+// AckSource is a Source whose values have to be acknowledged. Next returns
+// values of type *Acked, so they can be passed through operators that don't
+// know about acknowledgements.
+type AckSource interface {
+	Source
+
+	NextAck(ctx context.Context) (*Acked, error)
+}
+
+// OffsetSourceFunc opens a source that starts at the given offset.
+type OffsetSourceFunc func(ctx context.Context, offset int64) (Source, error)
+
+// NewAckSource returns an AckSource that numbers the values of the source
+// returned by open. It loads the checkpoint from store and opens the source
+// at the offset after it, or at zero if there is none. Whenever the highest
+// offset up to which all values have been acknowledged advances, it is saved
+// to store, so a restarted pipeline continues with the first value that was
+// not acknowledged.
+func NewAckSource(ctx context.Context, store CheckpointStore, open OffsetSourceFunc) (AckSource, error) {
+	committed, ok, err := store.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "luigi: failed to load checkpoint")
+	}
+	if !ok {
+		committed = -1
+	}
+
+	src, err := open(ctx, committed+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ackSource{
+		src:  src,
+		next: committed + 1,
+		tracker: &ackTracker{
+			store:     store,
+			committed: committed,
+			acked:     make(map[int64]struct{}),
+		},
+	}, nil
+}
+
+type ackSource struct {
+	src     Source
+	tracker *ackTracker
+
+	l    sync.Mutex
+	next int64
+}
+
+// Next implements the Source interface. It returns values of type *Acked.
+func (src *ackSource) Next(ctx context.Context) (interface{}, error) {
+	a, err := src.NextAck(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// NextAck implements the AckSource interface.
+func (src *ackSource) NextAck(ctx context.Context) (*Acked, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	v, err := src.src.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Acked{Value: v, Offset: src.next, tracker: src.tracker}
+	src.next++
+	return a, nil
+}
+
+// ackTracker keeps track of the highest contiguous acknowledged offset.
+type ackTracker struct {
+	store CheckpointStore
+
+	l         sync.Mutex
+	committed int64
+	acked     map[int64]struct{}
+}
+
+func (t *ackTracker) ack(offset int64) error {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	if offset <= t.committed {
+		return nil
+	}
+	t.acked[offset] = struct{}{}
+
+	prev := t.committed
+	for {
+		if _, ok := t.acked[t.committed+1]; !ok {
+			break
+		}
+		delete(t.acked, t.committed+1)
+		t.committed++
+	}
+
+	if t.committed == prev {
+		return nil
+	}
+
+	return errors.Wrap(t.store.Save(t.committed), "luigi: failed to save checkpoint")
+}
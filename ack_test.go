@@ -0,0 +1,66 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAckSource(t *testing.T) {
+	for name, store := range map[string]CheckpointStore{
+		"memory": NewMemoryCheckpoint(),
+		"file":   NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+
+			data := []interface{}{"a", "b", "c", "d", "e"}
+			open := func(_ context.Context, offset int64) (Source, error) {
+				src := SliceSource(data[offset:])
+				return &src, nil
+			}
+
+			src, err := NewAckSource(ctx, store, open)
+			r.NoError(err)
+
+			var acks []*Acked
+			for range data[:4] {
+				a, err := src.NextAck(ctx)
+				r.NoError(err)
+				acks = append(acks, a)
+			}
+			r.Equal("a", acks[0].Value)
+			r.Equal(int64(3), acks[3].Offset)
+
+			r.NoError(acks[1].Ack())
+			_, ok, err := store.Load()
+			r.NoError(err)
+			r.False(ok, "offset 0 isn't acknowledged yet")
+
+			r.NoError(acks[0].Ack())
+			r.NoError(acks[3].Ack())
+			r.NoError(acks[0].Ack())
+			offset, ok, err := store.Load()
+			r.NoError(err)
+			r.True(ok)
+			r.Equal(int64(1), offset, "offset 2 isn't acknowledged yet")
+
+			// restart
+			src, err = NewAckSource(ctx, store, open)
+			r.NoError(err)
+
+			v, err := src.Next(ctx)
+			r.NoError(err)
+			a := v.(*Acked)
+			r.Equal("c", a.Value)
+			r.Equal(int64(2), a.Offset)
+		})
+	}
+}
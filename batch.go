@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultBatchSize is the number of values moved at once by PumpBatch when
+// neither side limits it.
+const DefaultBatchSize = 64
+
+// BatchSource is implemented by Sources that can return several values at
+// once.
+type BatchSource interface {
+	Source
+
+	// NextBatch returns between one and max values. It blocks like Next
+	// until at least one value is available, and only returns an empty
+	// batch together with an error.
+	NextBatch(ctx context.Context, max int) ([]interface{}, error)
+}
+
+// BatchSink is implemented by Sinks that can take several values at once.
+type BatchSink interface {
+	Sink
+
+	// PourBatch writes all values to the sink, in order. If it fails after
+	// some of them were written, it returns a *BatchError. Any other error
+	// means that none were written.
+	PourBatch(ctx context.Context, vs []interface{}) error
+}
+
+// BatchError is returned by PourBatch when it fails partway.
+type BatchError struct {
+	// Rest holds the values that were not poured, starting with the one
+	// that failed.
+	Rest []interface{}
+
+	// Err is the error returned when pouring Rest[0].
+	Err error
+}
+
+func (err *BatchError) Error() string {
+	return fmt.Sprintf("luigi: failed to pour batch, %d values left: %v", len(err.Rest), err.Err)
+}
+
+// Cause returns the error returned when pouring Rest[0].
+func (err *BatchError) Cause() error { return err.Err }
+
+// Unwrap returns the error returned when pouring Rest[0].
+func (err *BatchError) Unwrap() error { return err.Err }
+
+// NextBatch reads up to max values from src, using NextBatch if src is a
+// BatchSource and a single call to Next otherwise.
+func NextBatch(ctx context.Context, src Source, max int) ([]interface{}, error) {
+	if bsrc, ok := src.(BatchSource); ok {
+		return bsrc.NextBatch(ctx, max)
+	}
+
+	v, err := src.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return []interface{}{v}, nil
+}
+
+// PourBatch writes vs to sink, using PourBatch if sink is a BatchSink and
+// calling Pour for each value otherwise.
+func PourBatch(ctx context.Context, sink Sink, vs []interface{}) error {
+	if bsink, ok := sink.(BatchSink); ok {
+		return bsink.PourBatch(ctx, vs)
+	}
+
+	for i, v := range vs {
+		err := sink.Pour(ctx, v)
+		if err != nil {
+			return batchError(vs, i, err)
+		}
+	}
+
+	return nil
+}
+
+// batchError returns the error for a batch that failed at vs[i].
+func batchError(vs []interface{}, i int, err error) error {
+	if i == 0 {
+		return err
+	}
+
+	return &BatchError{Rest: vs[i:], Err: err}
+}
+
+// batchSize returns how many values to move to dst at once.
+func batchSize(dst Sink) int {
+	n := Demand(dst)
+	if n == Unbounded || n > DefaultBatchSize {
+		return DefaultBatchSize
+	}
+	if n == 0 {
+		return 1
+	}
+
+	return n
+}
+
+// PumpBatch is like Pump, but moves values in batches, using NextBatch and
+// PourBatch.
+//
+// If pouring a batch fails, the values after the one that failed are put back
+// into src if it is an Unreader. Otherwise they are returned in a
+// *BatchError, so unlike with Pump more than one value may be taken out of
+// src.
+func PumpBatch(ctx context.Context, dst Sink, src Source) error {
+	if psrc, ok := src.(PushSource); ok {
+		return psrc.Push(ctx, dst)
+	}
+
+	for {
+		n := batchSize(dst)
+		request(src, dst)
+
+		vs, err := NextBatch(ctx, src, n)
+		if IsEOS(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		err = PourBatch(ctx, dst, vs)
+		if err != nil {
+			return unreadBatch(src, vs, err)
+		}
+	}
+}
+
+// unreadBatch puts the values of the failed batch vs that come after the
+// failing one back into src. It returns the error PumpBatch should return.
+func unreadBatch(src Source, vs []interface{}, err error) error {
+	bErr, ok := err.(*BatchError)
+	if !ok {
+		bErr = &BatchError{Rest: vs, Err: err}
+	}
+
+	if len(bErr.Rest) < 2 {
+		// like with Pump, only the value that failed is lost
+		return bErr.Err
+	}
+
+	unreader, ok := src.(Unreader)
+	if !ok {
+		return bErr
+	}
+
+	for i := len(bErr.Rest) - 1; i > 0; i-- {
+		if uErr := unreader.Unread(bErr.Rest[i]); uErr != nil {
+			return &BatchError{Rest: bErr.Rest[:i+1], Err: bErr.Err}
+		}
+	}
+
+	return bErr.Err
+}
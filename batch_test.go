@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSliceBatch(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src := SliceSource([]interface{}{1, 2, 3, 4, 5})
+
+	vs, err := src.NextBatch(ctx, 2)
+	r.NoError(err)
+	r.Equal([]interface{}{1, 2}, vs)
+
+	vs, err = NextBatch(ctx, &src, 10)
+	r.NoError(err)
+	r.Equal([]interface{}{3, 4, 5}, vs)
+
+	_, err = src.NextBatch(ctx, 10)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+
+	var out []interface{}
+	sink := NewSliceSink(&out)
+	r.NoError(PourBatch(ctx, sink, []interface{}{1, 2}))
+	r.NoError(sink.Close())
+	r.Error(PourBatch(ctx, sink, []interface{}{3}))
+	r.Equal([]interface{}{1, 2}, out)
+}
+
+func TestPipeBatch(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(4))
+
+	r.NoError(PourBatch(ctx, sink, []interface{}{1, 2, 3}))
+
+	vs, err := NextBatch(ctx, src, 10)
+	r.NoError(err)
+	r.Equal([]interface{}{1, 2, 3}, vs)
+
+	// fall back to blocking Pour once the buffer is full
+	done := make(chan error)
+	go func() {
+		done <- PourBatch(ctx, sink, []interface{}{4, 5, 6, 7, 8, 9})
+	}()
+
+	var out []interface{}
+	for len(out) < 6 {
+		vs, err := NextBatch(ctx, src, 4)
+		r.NoError(err)
+		r.True(len(vs) <= 4, "batch too large")
+		out = append(out, vs...)
+	}
+	r.NoError(<-done)
+	r.Equal([]interface{}{4, 5, 6, 7, 8, 9}, out)
+
+	r.NoError(sink.Close())
+	_, err = NextBatch(ctx, src, 10)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+	r.Equal(ErrPourToClosedSink, PourBatch(ctx, sink, []interface{}{10}))
+}
+
+func TestPipeBatchMax(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(2))
+	r.NoError(PourBatch(ctx, sink, []interface{}{1, 2}))
+
+	vs, err := NextBatch(ctx, src, 0)
+	r.NoError(err)
+	r.Equal([]interface{}{1}, vs, "at least one value should be returned")
+}
+
+func TestPumpBatch(t *testing.T) {
+	r := require.New(t)
+
+	in := make([]interface{}, 1000)
+	for i := range in {
+		in[i] = i
+	}
+
+	src := SliceSource(append([]interface{}(nil), in...))
+
+	var out []interface{}
+	r.NoError(PumpBatch(context.Background(), NewSliceSink(&out), &src))
+	r.Equal(in, out)
+}
+
+func TestPumpBatchError(t *testing.T) {
+	r := require.New(t)
+
+	failErr := errors.New("sink failed")
+	var out []interface{}
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if v == 3 {
+			return failErr
+		}
+		out = append(out, v)
+		return nil
+	}
+
+	src := SliceSource([]interface{}{1, 2, 3, 4, 5})
+	err := PumpBatch(context.Background(), failing, &src)
+
+	var bErr *BatchError
+	r.True(errors.As(err, &bErr), "expected a BatchError, got %v", err)
+	r.Equal(failErr, bErr.Err)
+	r.Equal([]interface{}{3, 4, 5}, bErr.Rest, "the values that were not poured should be returned")
+	r.Equal([]interface{}{1, 2}, out)
+}
+
+func TestPumpPipeError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(5))
+	for i := 1; i <= 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	failErr := errors.New("sink failed")
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		return failErr
+	}
+	r.Equal(failErr, Pump(ctx, failing, src), "the error of the sink should be returned unchanged")
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(2, v, "only the value that failed should be lost")
+}
+
+func benchmarkPipe(b *testing.B, batch int) {
+	ctx := context.Background()
+	src, sink := NewPipe(WithBuffer(1024))
+
+	go func() {
+		vs := make([]interface{}, batch)
+		for i := 0; i < b.N; i += batch {
+			if batch == 1 {
+				sink.Pour(ctx, i)
+				continue
+			}
+
+			for j := range vs {
+				vs[j] = i + j
+			}
+			sink.(BatchSink).PourBatch(ctx, vs)
+		}
+		sink.Close()
+	}()
+
+	b.ResetTimer()
+	for {
+		var err error
+		if batch == 1 {
+			_, err = src.Next(ctx)
+		} else {
+			_, err = src.(BatchSource).NextBatch(ctx, batch)
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+func BenchmarkPipe(b *testing.B)        { benchmarkPipe(b, 1) }
+func BenchmarkPipeBatch16(b *testing.B) { benchmarkPipe(b, 16) }
+func BenchmarkPipeBatch64(b *testing.B) { benchmarkPipe(b, 64) }
+
+// oneByOne hides the batch methods of a SliceSource.
+type oneByOne struct{ Source }
+
+func benchmarkPumpSlice(b *testing.B, batched bool) {
+	in := make([]interface{}, b.N)
+
+	var src Source = (*SliceSource)(&in)
+	if !batched {
+		src = oneByOne{src}
+	}
+
+	out := make([]interface{}, 0, b.N)
+	sink := NewSliceSink(&out)
+
+	b.ResetTimer()
+	if batched {
+		PumpBatch(context.Background(), sink, src)
+	} else {
+		Pump(context.Background(), sink, src)
+	}
+}
+
+func BenchmarkPumpSlice(b *testing.B)      { benchmarkPumpSlice(b, false) }
+func BenchmarkPumpSliceBatch(b *testing.B) { benchmarkPumpSlice(b, true) }
@@ -6,9 +6,12 @@ package luigi // import "github.com/ssbc/go-luigi"
 
 import (
 	"context"
+	"reflect"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 )
 
 // Broadcast is an interface for registering one or more Sinks to recieve
@@ -18,51 +21,474 @@ type Broadcast interface {
 	Register(dst Sink) func()
 }
 
+// SlowPolicy decides what a broadcast using concurrent delivery does when the
+// buffer of a registered sink is full.
+type SlowPolicy int
+
+const (
+	// SlowBlock makes Pour wait until the sink has room. This is the
+	// default.
+	SlowBlock SlowPolicy = iota
+
+	// SlowDrop drops the value for that sink.
+	SlowDrop
+
+	// SlowEvict unregisters the sink and closes it with ErrSlowSubscriber.
+	SlowEvict
+)
+
+// ErrSlowSubscriber is passed to the CloseWithError method of sinks evicted
+// by the SlowEvict policy.
+var ErrSlowSubscriber = &Error{Kind: ErrTooSlow, Msg: "luigi: broadcast subscriber too slow"}
+
+// ErrBroadcastClosed is returned when registering on a closed broadcast.
+var ErrBroadcastClosed = &Error{Kind: ErrClosed, Msg: "luigi: broadcast closed"}
+
+type broadcastOpts struct {
+	concurrent bool
+	bufferSize int
+	policy     SlowPolicy
+	keyFunc    func(context.Context, interface{}) (interface{}, error)
+	onError    func(Sink, error)
+	clock      Clock
+}
+
+// BroadcastOpt configures NewBroadcast's behavior
+type BroadcastOpt func(*broadcastOpts) error
+
+// WithConcurrentDelivery pours values into every registered sink from its own
+// goroutine, through a buffer of bufSize values. A slow sink then doesn't
+// hold up the others, and a sink that fails is unregistered and closed
+// instead of failing Pour.
+func WithConcurrentDelivery(bufSize int) BroadcastOpt {
+	return BroadcastOpt(func(opts *broadcastOpts) error {
+		if bufSize < 0 {
+			return invalidf("negative buffer size %d", bufSize)
+		}
+
+		opts.concurrent = true
+		opts.bufferSize = bufSize
+		return nil
+	})
+}
+
+// WithSlowPolicy sets what happens when a sink's buffer is full. It requires
+// WithConcurrentDelivery.
+func WithSlowPolicy(policy SlowPolicy) BroadcastOpt {
+	return BroadcastOpt(func(opts *broadcastOpts) error {
+		if policy < SlowBlock || policy > SlowEvict {
+			return invalidf("unknown slow policy %d", policy)
+		}
+
+		opts.policy = policy
+		return nil
+	})
+}
+
+// OnDeliveryError sets a func that is called with the sink and the error
+// whenever pouring into a registered sink fails. With concurrent delivery
+// this is the only way to learn about such failures, since they don't fail
+// Pour. It is also called for sinks evicted by the SlowEvict policy.
+//
+// f is called from the goroutine that delivered the value and must not
+// block.
+func OnDeliveryError(f func(dst Sink, err error)) BroadcastOpt {
+	return BroadcastOpt(func(opts *broadcastOpts) error {
+		if f == nil {
+			return invalidf("nil error func")
+		}
+
+		opts.onError = f
+		return nil
+	})
+}
+
+// DeliveryErrors is like OnDeliveryError, but sends the errors to ch. Errors
+// are dropped if ch is not ready.
+func DeliveryErrors(ch chan<- error) BroadcastOpt {
+	if ch == nil {
+		return BroadcastOpt(func(*broadcastOpts) error {
+			return invalidf("nil error channel")
+		})
+	}
+
+	return OnDeliveryError(func(_ Sink, err error) {
+		select {
+		case ch <- err:
+		default:
+		}
+	})
+}
+
 // NewBroadcast returns the Sink, to write to the broadcaster, and the new
 // broadcast instance.
-func NewBroadcast() (Sink, Broadcast) {
-	bcst := broadcast{sinks: make(map[*Sink]struct{})}
+//
+// By default Pour writes to the registered sinks one after another and
+// returns the first error. See WithConcurrentDelivery for an alternative.
+//
+// The returned Broadcast is a FilteredBroadcast, and the returned Sink an
+// ErrorCloser that passes the error on to the registered sinks.
+func NewBroadcast(opts ...BroadcastOpt) (Sink, Broadcast) {
+	bOpts := broadcastOpts{clock: RealClock}
+
+	for i, opt := range opts {
+		err := opt(&bOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid broadcast option %d", i))
+		}
+	}
+
+	if bOpts.policy != SlowBlock && !bOpts.concurrent {
+		panic(invalidf("luigi: slow policy requires concurrent delivery"))
+	}
+
+	bcst := broadcast{
+		opts:  bOpts,
+		sinks: make(map[*Sink]*subscriber),
+		keyed: make(map[interface{}]map[*Sink]*subscriber),
+	}
 
 	return (*broadcastSink)(&bcst), &bcst
 }
 
 type broadcast struct {
 	sync.Mutex
-	sinks map[*Sink]struct{}
+	opts broadcastOpts
+
+	// sinks holds the subscribers registered without a key, and keyed the
+	// others by their key
+	sinks map[*Sink]*subscriber
+	keyed map[interface{}]map[*Sink]*subscriber
+
+	dropped uint64
+
+	// closed is set by Close, and closeErr by CloseWithError
+	closed   bool
+	closeErr error
+}
+
+// subscriber is a registered sink. The channels are only used if delivery is
+// concurrent.
+type subscriber struct {
+	registerOpts
+
+	id   *Sink
+	sink Sink
+
+	ch     chan interface{}
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// flush is closed to deliver the buffered values and stop
+	flush chan struct{}
+	done  chan struct{}
+
+	// onEvict is called when the subscriber is unregistered because its
+	// sink failed or was too slow
+	onEvict func()
 }
 
-// Register implements the Broadcast interface.
+// Register implements the Broadcast interface. Sinks registered after Close
+// are still registered and get the values poured later. Registering a nil
+// sink does nothing.
 func (bcst *broadcast) Register(sink Sink) func() {
+	if sink == nil {
+		return func() {}
+	}
+
+	bcst.Lock()
+	defer bcst.Unlock()
+	return bcst.register(&subscriber{id: &sink, sink: sink})
+}
+
+// RegisterWithOpts implements the FilteredBroadcast interface. It returns
+// ErrBroadcastClosed after Close.
+func (bcst *broadcast) RegisterWithOpts(sink Sink, opts ...RegisterOpt) (func(), error) {
+	sub, err := bcst.newSubscriber(sink, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return bcst.add(sub)
+}
+
+// newSubscriber checks the options and returns a subscriber for sink.
+func (bcst *broadcast) newSubscriber(sink Sink, opts []RegisterOpt) (*subscriber, error) {
+	if sink == nil {
+		return nil, invalidf("luigi: register nil sink")
+	}
+
+	sub := &subscriber{id: &sink, sink: sink}
+	for i, opt := range opts {
+		err := opt(&sub.registerOpts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "luigi: invalid register option %d", i)
+		}
+	}
+
+	if sub.hasKey && bcst.opts.keyFunc == nil {
+		return nil, invalidf("luigi: register with key on broadcast without key func")
+	}
+
+	return sub, nil
+}
+
+// isClosed returns whether the broadcast was closed.
+func (bcst *broadcast) isClosed() bool {
 	bcst.Lock()
 	defer bcst.Unlock()
-	bcst.sinks[&sink] = struct{}{}
 
-	return func() {
-		bcst.Lock()
-		defer bcst.Unlock()
-		delete(bcst.sinks, &sink)
+	return bcst.closed
+}
+
+// closeLate closes a sink that could not be registered because the broadcast
+// is closed, like the registered sinks were closed.
+func (bcst *broadcast) closeLate(sink Sink) {
+	bcst.Lock()
+	closeErr := bcst.closeErr
+	bcst.Unlock()
+
+	if closeErr != nil {
+		closeWithError(sink, closeErr)
+	} else {
 		sink.Close()
 	}
 }
 
+// add registers sub and returns the func that unregisters it, or
+// ErrBroadcastClosed after Close.
+func (bcst *broadcast) add(sub *subscriber) (func(), error) {
+	bcst.Lock()
+	defer bcst.Unlock()
+
+	if bcst.closed {
+		return nil, ErrBroadcastClosed
+	}
+
+	return bcst.register(sub), nil
+}
+
+// register registers sub and returns the func that unregisters it. It must
+// be called with the lock held.
+func (bcst *broadcast) register(sub *subscriber) func() {
+	if sub.hasKey {
+		if bcst.keyed[sub.key] == nil {
+			bcst.keyed[sub.key] = make(map[*Sink]*subscriber)
+		}
+		bcst.keyed[sub.key][sub.id] = sub
+	} else {
+		bcst.sinks[sub.id] = sub
+	}
+
+	if bcst.opts.concurrent {
+		sub.ch = make(chan interface{}, bcst.opts.bufferSize)
+		sub.ctx, sub.cancel = context.WithCancel(context.Background())
+		sub.flush = make(chan struct{})
+		sub.done = make(chan struct{})
+		go bcst.deliver(sub)
+	}
+
+	return func() {
+		if !bcst.unregister(sub) {
+			return
+		}
+
+		if sub.cancel != nil {
+			sub.cancel()
+			<-sub.done
+		}
+		sub.sink.Close()
+	}
+}
+
+// accept returns whether v should be delivered to sub, and in what form.
+func (bcst *broadcast) accept(ctx context.Context, sub *subscriber, v interface{}) (interface{}, bool, error) {
+	if sub.hasKey {
+		k, err := bcst.opts.keyFunc(ctx, v)
+		if err != nil || k != sub.key {
+			return nil, false, err
+		}
+	}
+
+	return sub.prepare(ctx, v)
+}
+
+// subscribers returns all subscribers. It must be called with the lock held.
+func (bcst *broadcast) subscribers() []*subscriber {
+	subs := make([]*subscriber, 0, len(bcst.sinks))
+	for _, sub := range bcst.sinks {
+		subs = append(subs, sub)
+	}
+	for _, keyed := range bcst.keyed {
+		for _, sub := range keyed {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs
+}
+
+// targets returns the subscribers v might be delivered to, using the index
+// if there is a key func.
+func (bcst *broadcast) targets(ctx context.Context, v interface{}) ([]*subscriber, error) {
+	var (
+		k      interface{}
+		hasKey bool
+	)
+	if bcst.opts.keyFunc != nil {
+		var err error
+		k, err = bcst.opts.keyFunc(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, invalidf("luigi: key of type %T is not comparable", k)
+		}
+		hasKey = true
+	}
+
+	bcst.Lock()
+	defer bcst.Unlock()
+
+	subs := make([]*subscriber, 0, len(bcst.sinks))
+	for _, sub := range bcst.sinks {
+		subs = append(subs, sub)
+	}
+	if hasKey {
+		for _, sub := range bcst.keyed[k] {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs, nil
+}
+
+// unregister removes the subscriber and returns whether it was registered.
+func (bcst *broadcast) unregister(sub *subscriber) bool {
+	bcst.Lock()
+	defer bcst.Unlock()
+
+	sinks := bcst.sinks
+	if sub.hasKey {
+		sinks = bcst.keyed[sub.key]
+	}
+
+	if sinks[sub.id] != sub {
+		return false
+	}
+
+	delete(sinks, sub.id)
+	if sub.hasKey && len(sinks) == 0 {
+		delete(bcst.keyed, sub.key)
+	}
+	return true
+}
+
+// evict unregisters sub and closes its sink with err once its delivery
+// goroutine has stopped.
+func (bcst *broadcast) evict(sub *subscriber, err error) {
+	if !bcst.unregister(sub) {
+		return
+	}
+
+	bcst.report(sub, err)
+	if sub.onEvict != nil {
+		sub.onEvict()
+	}
+	go func() {
+		sub.cancel()
+		<-sub.done
+		closeWithError(sub.sink, err)
+	}()
+}
+
+// deliver pours the values sent to sub into its sink until it is cancelled
+// or flushed.
+func (bcst *broadcast) deliver(sub *subscriber) {
+	defer close(sub.done)
+
+	for {
+		select {
+		case v := <-sub.ch:
+			if !bcst.pourSub(sub, v) {
+				return
+			}
+		case <-sub.flush:
+			for {
+				select {
+				case v := <-sub.ch:
+					if !bcst.pourSub(sub, v) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-sub.ctx.Done():
+			return
+		}
+	}
+}
+
+// pourSub pours v into the subscriber's sink. If that fails, the subscriber
+// is unregistered and false is returned.
+func (bcst *broadcast) pourSub(sub *subscriber, v interface{}) bool {
+	err := sub.sink.Pour(sub.ctx, v)
+	if err == nil {
+		return true
+	}
+
+	if sub.ctx.Err() == nil && bcst.unregister(sub) {
+		bcst.report(sub, err)
+		if sub.onEvict != nil {
+			sub.onEvict()
+		}
+		sub.cancel()
+		closeWithError(sub.sink, err)
+	}
+
+	return false
+}
+
+// report passes the error of sub to the OnDeliveryError func, if set.
+func (bcst *broadcast) report(sub *subscriber, err error) {
+	if bcst.opts.onError != nil {
+		bcst.opts.onError(sub.sink, err)
+	}
+}
+
+// closeWithError closes sink with err if it is an ErrorCloser.
+func closeWithError(sink Sink, err error) error {
+	if ec, ok := sink.(ErrorCloser); ok {
+		return ec.CloseWithError(err)
+	}
+
+	return sink.Close()
+}
+
 type broadcastSink broadcast
 
 // Pour implements the Sink interface.
 func (bcst *broadcastSink) Pour(ctx context.Context, v interface{}) error {
+	if bcst.opts.concurrent {
+		return bcst.pourConcurrent(ctx, v)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	bcst.Lock()
-	sinks := make([]Sink, 0, len(bcst.sinks))
-
-	for sink := range bcst.sinks {
-		sinks = append(sinks, *sink)
+	subs, err := (*broadcast)(bcst).targets(ctx, v)
+	if err != nil {
+		return err
 	}
-	bcst.Unlock()
 
-	for _, s := range sinks {
-		err := s.Pour(ctx, v)
+	for _, sub := range subs {
+		v, ok, err := sub.prepare(ctx, v)
+		if err == nil && ok {
+			err = sub.sink.Pour(ctx, v)
+		}
 		if err != nil {
+			(*broadcast)(bcst).report(sub, err)
 			return err
 		}
 	}
@@ -70,21 +496,124 @@ func (bcst *broadcastSink) Pour(ctx context.Context, v interface{}) error {
 	return nil
 }
 
-// Close implements the Sink interface.
+// pourConcurrent hands v to the delivery goroutine of every subscriber. A
+// subscriber whose filter or map func fails is evicted.
+func (bcst *broadcastSink) pourConcurrent(ctx context.Context, v interface{}) error {
+	subs, err := (*broadcast)(bcst).targets(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		v, ok, err := sub.prepare(ctx, v)
+		if err != nil {
+			(*broadcast)(bcst).evict(sub, err)
+			continue
+		} else if !ok {
+			continue
+		}
+
+		switch bcst.opts.policy {
+		case SlowBlock:
+			select {
+			case sub.ch <- v:
+			case <-sub.ctx.Done():
+			case <-ctx.Done():
+				return errors.Wrap(ctx.Err(), "luigi pour done")
+			}
+		case SlowDrop:
+			select {
+			case sub.ch <- v:
+			default:
+				atomic.AddUint64(&bcst.dropped, 1)
+			}
+		case SlowEvict:
+			select {
+			case sub.ch <- v:
+			default:
+				(*broadcast)(bcst).evict(sub, ErrSlowSubscriber)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Demand implements the Demander interface. It is the smallest demand of all
+// registered sinks, so no sink receives more values than it asked for. With
+// concurrent delivery it is the smallest free space in their buffers, or
+// Unbounded if values are not blocked on slow sinks.
+func (bcst *broadcastSink) Demand() int {
+	bcst.Lock()
+	defer bcst.Unlock()
+
+	if bcst.opts.concurrent && bcst.opts.policy != SlowBlock {
+		return Unbounded
+	}
+
+	demand := Unbounded
+	for _, sub := range (*broadcast)(bcst).subscribers() {
+		if bcst.opts.concurrent {
+			demand = minDemand(demand, cap(sub.ch)-len(sub.ch))
+		} else {
+			demand = minDemand(demand, Demand(sub.sink))
+		}
+	}
+
+	return demand
+}
+
+// Dropped implements the DropCounter interface. It counts the values dropped
+// by the SlowDrop policy.
+func (bcst *broadcastSink) Dropped() uint64 {
+	return atomic.LoadUint64(&bcst.dropped)
+}
+
+// Close implements the Sink interface. With concurrent delivery, the
+// buffered values are delivered before the sinks are closed.
 func (bcst *broadcastSink) Close() error {
+	return bcst.close(nil)
+}
+
+// CloseWithError implements the ErrorCloser interface. It is like Close, but
+// closes the registered sinks that are ErrorClosers with err.
+func (bcst *broadcastSink) CloseWithError(err error) error {
+	return bcst.close(err)
+}
+
+func (bcst *broadcastSink) close(closeErr error) error {
 	var sinks []Sink
 
 	bcst.Lock()
-	defer bcst.Unlock()
+	if !bcst.closed {
+		bcst.closed = true
+		bcst.closeErr = closeErr
+	}
+	if bcst.opts.concurrent {
+		subs := (*broadcast)(bcst).subscribers()
+		bcst.sinks = make(map[*Sink]*subscriber)
+		bcst.keyed = make(map[interface{}]map[*Sink]*subscriber)
+		bcst.Unlock()
 
-	sinks = make([]Sink, 0, len(bcst.sinks))
+		for _, sub := range subs {
+			close(sub.flush)
+		}
+		for _, sub := range subs {
+			<-sub.done
+			sub.cancel()
+			sinks = append(sinks, sub.sink)
+		}
+	} else {
+		defer bcst.Unlock()
 
-	for sink := range bcst.sinks {
-		sinks = append(sinks, *sink)
+		for _, sub := range (*broadcast)(bcst).subscribers() {
+			sinks = append(sinks, sub.sink)
+		}
 	}
 
 	var (
 		wg   sync.WaitGroup
+		l    sync.Mutex
 		merr *multierror.Error
 	)
 
@@ -93,9 +622,16 @@ func (bcst *broadcastSink) Close() error {
 		go func(sink Sink) {
 			defer wg.Done()
 
-			err := sink.Close()
+			var err error
+			if closeErr != nil {
+				err = closeWithError(sink, closeErr)
+			} else {
+				err = sink.Close()
+			}
 			if err != nil {
+				l.Lock()
 				merr = multierror.Append(merr, err)
+				l.Unlock()
 				return
 			}
 		}(sink_)
@@ -6,10 +6,12 @@ package luigi // import "github.com/ssbc/go-luigi"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/require"
 )
 
 func ExampleBroadcast() {
@@ -159,3 +161,240 @@ func TestBroadcast(t *testing.T) {
 	}
 
 }
+
+func TestBroadcastConcurrent(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewBroadcast(WithConcurrentDelivery(2))
+
+	// nobody reads from slow
+	_, slow := NewPipe()
+	fastSrc, fast := NewPipe(WithBuffer(10))
+	bcst.Register(slow)
+	bcst.Register(fast)
+
+	failErr := errors.New("sink failed")
+	closeErr := make(chan error, 1)
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if err != nil {
+			closeErr <- err
+			return nil
+		}
+		if v == 2 {
+			return failErr
+		}
+		return nil
+	}
+	bcst.Register(failing)
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2), "a failing sink should not fail Pour")
+	r.Equal(failErr, <-closeErr, "the failing sink should be closed with its error")
+	r.NoError(sink.Pour(ctx, 3), "a failing sink should be unregistered")
+
+	for _, want := range []interface{}{1, 2, 3} {
+		v, err := fastSrc.Next(ctx)
+		r.NoError(err)
+		r.Equal(want, v, "the slow sink should not hold up the others")
+	}
+}
+
+func TestBroadcastSlowDrop(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewBroadcast(WithConcurrentDelivery(1), WithSlowPolicy(SlowDrop))
+	src, slow := NewPipe()
+	bcst.Register(slow)
+
+	for i := 0; i < 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	dropped := sink.(DropCounter).Dropped()
+	r.True(dropped >= 3, "at most two values fit into the pipeline, dropped %d", dropped)
+
+	errc := make(chan error)
+	go func() { errc <- sink.Close() }()
+
+	var out []interface{}
+	r.NoError(Pump(ctx, NewSliceSink(&out), src))
+	r.NoError(<-errc)
+	r.Len(out, 5-int(dropped), "buffered values should be delivered on close")
+	r.Equal(0, out[0])
+}
+
+func TestBroadcastSlowEvict(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewBroadcast(WithConcurrentDelivery(0), WithSlowPolicy(SlowEvict))
+	src, slow := NewPipe()
+	bcst.Register(slow)
+
+	for i := 0; i < 3; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	for {
+		_, err := src.Next(ctx)
+		if err != nil {
+			r.Equal(ErrSlowSubscriber, err)
+			break
+		}
+	}
+
+	r.Equal(Unbounded, sink.(Demander).Demand(), "evicting sinks never blocks Pour")
+}
+
+func TestBroadcastFiltered(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	type msg struct {
+		author string
+		seq    int
+	}
+	author := func(_ context.Context, v interface{}) (interface{}, error) {
+		return v.(msg).author, nil
+	}
+	seq := func(_ context.Context, v interface{}) (interface{}, error) {
+		return v.(msg).seq, nil
+	}
+	odd := func(_ context.Context, v interface{}) (bool, error) {
+		return v.(msg).seq%2 == 1, nil
+	}
+
+	for name, concurrent := range map[string]bool{"sync": false, "concurrent": true} {
+		t.Run(name, func(t *testing.T) {
+			opts := []BroadcastOpt{WithKeyFunc(author)}
+			if concurrent {
+				opts = append(opts, WithConcurrentDelivery(10))
+			}
+			sink, bcst := NewBroadcast(opts...)
+
+			var all, alice, aliceOdd []interface{}
+			_, err := RegisterWithOpts(bcst, NewSliceSink(&all), WithMap(seq))
+			r.NoError(err)
+			_, err = RegisterWithOpts(bcst, NewSliceSink(&alice), WithKey("alice"), WithMap(seq))
+			r.NoError(err)
+			_, err = RegisterWithOpts(bcst, NewSliceSink(&aliceOdd), WithKey("alice"), WithFilter(odd), WithMap(seq))
+			r.NoError(err)
+
+			r.NoError(sink.Pour(ctx, msg{"alice", 1}))
+			r.NoError(sink.Pour(ctx, msg{"bob", 2}))
+			r.NoError(sink.Pour(ctx, msg{"alice", 3}))
+			r.NoError(sink.Pour(ctx, msg{"alice", 4}))
+			r.NoError(sink.Close())
+
+			r.Equal([]interface{}{1, 2, 3, 4}, all)
+			r.Equal([]interface{}{1, 3, 4}, alice)
+			r.Equal([]interface{}{1, 3}, aliceOdd)
+		})
+	}
+}
+
+func TestBroadcastRegisterInvalid(t *testing.T) {
+	r := require.New(t)
+
+	_, bcst := NewBroadcast()
+	_, err := RegisterWithOpts(bcst, NewSliceSink(new([]interface{})), WithKey("alice"))
+	r.Error(err, "keys require a key func")
+	_, err = RegisterWithOpts(bcst, NewSliceSink(new([]interface{})), WithFilter(nil))
+	r.Error(err)
+	_, err = RegisterWithOpts(bcst, nil)
+	r.Error(err)
+
+	_, bcst = NewBroadcast(WithKeyFunc(func(_ context.Context, v interface{}) (interface{}, error) {
+		return v, nil
+	}))
+	_, err = RegisterWithOpts(bcst, NewSliceSink(new([]interface{})), WithKey([]int{1}))
+	r.Error(err, "keys must be comparable")
+
+	_, err = RegisterWithOpts(NewObservable(nil), NewSliceSink(new([]interface{})), WithKey("alice"))
+	r.Error(err, "observables have no key func")
+}
+
+func TestBroadcastDeliveryErrors(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	failErr := errors.New("sink failed")
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if err == nil && v == 2 {
+			return failErr
+		}
+		return nil
+	}
+
+	var reported []error
+	sink, bcst := NewBroadcast(OnDeliveryError(func(dst Sink, err error) {
+		reported = append(reported, err)
+	}))
+	bcst.Register(failing)
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.Equal(failErr, sink.Pour(ctx, 2))
+	r.Equal([]error{failErr}, reported)
+
+	errc := make(chan error, 1)
+	sink, bcst = NewBroadcast(WithConcurrentDelivery(1), DeliveryErrors(errc))
+	bcst.Register(failing)
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2), "concurrent delivery does not fail Pour")
+	r.Equal(failErr, <-errc)
+	r.NoError(sink.Close())
+}
+
+func TestBroadcastCloseWithError(t *testing.T) {
+	closeErr := errors.New("upstream failed")
+	for name, concurrent := range map[string]bool{"sync": false, "concurrent": true} {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			var opts []BroadcastOpt
+			if concurrent {
+				opts = append(opts, WithConcurrentDelivery(1))
+			}
+			sink, bcst := NewErrorBroadcast(opts...)
+
+			src, dst := NewPipe(WithBuffer(1))
+			var closed []interface{}
+			plain := NewSliceSink(&closed)
+			_, err := bcst.Register(dst)
+			r.NoError(err)
+			_, err = bcst.Register(plain)
+			r.NoError(err)
+
+			r.NoError(sink.(ErrorCloser).CloseWithError(closeErr))
+			_, err = src.Next(context.Background())
+			r.Equal(closeErr, err, "ErrorClosers should receive the error")
+
+			_, err = bcst.Register(NewSliceSink(new([]interface{})))
+			r.True(errors.Is(err, ErrClosed), "registering on a closed broadcast should fail")
+		})
+	}
+
+}
+
+func TestBroadcastRegisterAfterClose(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewBroadcast()
+	r.NoError(sink.(ErrorCloser).CloseWithError(errors.New("upstream failed")))
+
+	var out []interface{}
+	cancel := bcst.Register(NewSliceSink(&out))
+	defer cancel()
+	r.NoError(sink.Pour(ctx, 1))
+	r.Equal([]interface{}{1}, out, "sinks registered after close should still get values")
+
+	r.NotPanics(func() {
+		bcst.Register(nil)()
+		r.NoError(sink.Pour(ctx, 2))
+	}, "registering a nil sink should do nothing")
+	r.Equal([]interface{}{1, 2}, out)
+}
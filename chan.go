@@ -7,6 +7,7 @@ package luigi // import "github.com/ssbc/go-luigi"
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -14,6 +15,13 @@ import (
 type pipeOpts struct {
 	bufferSize  int
 	nonBlocking bool
+	overflow    OverflowPolicy
+	pourTimeout time.Duration
+	onDrop      func(interface{})
+
+	unbounded       bool
+	highWaterMark   int
+	onHighWaterMark func(int) error
 }
 
 // PipeOpt configures NewPipes behavior
@@ -48,6 +56,23 @@ func NewPipe(opts ...PipeOpt) (Source, Sink) {
 		}
 	}
 
+	if pOpts.overflow != OverflowBlock || pOpts.pourTimeout != 0 {
+		if pOpts.nonBlocking {
+			panic(invalidf("luigi: overflow policy and pour timeout need a blocking pipe"))
+		}
+		if pOpts.unbounded {
+			panic(invalidf("luigi: overflow policy and pour timeout need a bounded buffer"))
+		}
+	}
+
+	if pOpts.unbounded {
+		return newQueuePipe(pOpts)
+	}
+
+	if pOpts.overflow == OverflowDropOldest && pOpts.bufferSize < 1 {
+		panic(invalidf("luigi: OverflowDropOldest needs a buffer"))
+	}
+
 	ch := make(chan interface{}, pOpts.bufferSize)
 
 	// TODO: it seems like at this point we could turn closeCh into a chan error
@@ -57,18 +82,34 @@ func NewPipe(opts ...PipeOpt) (Source, Sink) {
 	var closeLock sync.Mutex
 	var closeErr error
 
+	demand := newPipeDemand()
+	stats := newPipeCounters()
+
+	var evict <-chan interface{}
+	if pOpts.overflow == OverflowDropOldest {
+		evict = ch
+	}
+
 	return &chanSource{
 			ch:          ch,
 			closeCh:     closeCh,
 			closeLock:   &closeLock,
 			closeErr:    &closeErr,
 			nonBlocking: pOpts.nonBlocking,
+			demand:      demand,
+			stats:       stats,
 		}, &chanSink{
 			ch:          ch,
 			closeCh:     closeCh,
 			closeLock:   &closeLock,
 			closeErr:    &closeErr,
 			nonBlocking: pOpts.nonBlocking,
+			demand:      demand,
+			stats:       stats,
+			overflow:    pOpts.overflow,
+			pourTimeout: pOpts.pourTimeout,
+			onDrop:      pOpts.onDrop,
+			evict:       evict,
 		}
 }
 
@@ -78,10 +119,18 @@ type chanSource struct {
 	closeLock   *sync.Mutex
 	closeCh     chan struct{}
 	closeErr    *error
+	demand      *pipeDemand
+	stats       *pipeCounters
 }
 
 // Next implements the Source interface.
 func (src *chanSource) Next(ctx context.Context) (v interface{}, err error) {
+	defer func() {
+		if err == nil {
+			src.stats.addRead(1)
+		}
+	}()
+
 	if src.nonBlocking { // TODO: make two implementations of this (blocking and non-blocking) to untangle this mess
 		select {
 		case v = <-src.ch:
@@ -99,9 +148,12 @@ func (src *chanSource) Next(ctx context.Context) (v interface{}, err error) {
 				}
 			}
 		default:
-			err = errors.New("channel not ready for reading")
+			err = ErrNotReadyForReading
 		}
 	} else {
+		src.demand.startWaiting()
+		defer src.demand.doneWaiting()
+
 		select {
 		case v = <-src.ch:
 		case <-src.closeCh:
@@ -139,6 +191,40 @@ func (src *chanSource) Next(ctx context.Context) (v interface{}, err error) {
 	return v, err
 }
 
+// NextBatch implements the BatchSource interface. It waits for the first
+// value like Next and then takes whatever else is buffered, up to max values.
+func (src *chanSource) NextBatch(ctx context.Context, max int) ([]interface{}, error) {
+	v, err := src.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if max < 1 {
+		max = 1
+	}
+
+	vs := make([]interface{}, 1, max)
+	vs[0] = v
+
+	for len(vs) < max {
+		select {
+		case v = <-src.ch:
+			vs = append(vs, v)
+			src.stats.addRead(1)
+		default:
+			return vs, nil
+		}
+	}
+
+	return vs, nil
+}
+
+// Request implements the Requester interface. The demand is advertised by
+// the Demand method of the other end of the pipe.
+func (src *chanSource) Request(n int) {
+	src.demand.request(n)
+}
+
 type chanSink struct {
 	ch          chan<- interface{}
 	nonBlocking bool
@@ -146,10 +232,23 @@ type chanSink struct {
 	closeCh     chan struct{}
 	closeErr    *error
 	closeOnce   sync.Once
+	demand      *pipeDemand
+	stats       *pipeCounters
+
+	overflow    OverflowPolicy
+	pourTimeout time.Duration
+	onDrop      func(interface{})
+	evict       <-chan interface{}
 }
 
 // Pour implements the Sink interface.
-func (sink *chanSink) Pour(ctx context.Context, v interface{}) error {
+func (sink *chanSink) Pour(ctx context.Context, v interface{}) (err error) {
+	defer func() {
+		if err == nil {
+			sink.stats.addPoured(1)
+		}
+	}()
+
 	select {
 	case <-sink.closeCh:
 		return ErrPourToClosedSink
@@ -170,27 +269,56 @@ func (sink *chanSink) Pour(ctx context.Context, v interface{}) error {
 				return ctx.Err()
 			}
 		default:
-			return errors.New("channel not ready for writing")
+			return ErrNotReadyForWriting
 		}
 	} else {
+		return sink.pourOverflow(ctx, v)
+	}
+}
+
+// PourBatch implements the BatchSink interface. Values that fit into the
+// buffer are sent directly, and it only falls back to Pour when it's full.
+func (sink *chanSink) PourBatch(ctx context.Context, vs []interface{}) error {
+	select {
+	case <-sink.closeCh:
+		return ErrPourToClosedSink
+	default:
+	}
+
+	for i, v := range vs {
 		select {
 		case sink.ch <- v:
-			return nil
-		case <-sink.closeCh:
-			return ErrPourToClosedSink
-		case <-ctx.Done():
-			// we may be called with closed context on a closed sink. in that case we want to return the closed sink error.
-			select {
-			case <-sink.closeCh:
-				return ErrPourToClosedSink
-			default:
-				return ctx.Err()
-			}
+			sink.stats.addPoured(1)
+			continue
+		default:
+		}
+
+		err := sink.Pour(ctx, v)
+		if err != nil {
+			return batchError(vs, i, err)
 		}
 	}
+
+	return nil
 }
 
-var ErrPourToClosedSink = errors.New("pour to closed sink")
+// Demand implements the Demander interface. It is the free space in the
+// buffer plus the demand of the reading end, which is either the number of
+// waiting Next calls or what was passed to Request.
+func (sink *chanSink) Demand() int {
+	select {
+	case <-sink.closeCh:
+		return 0
+	default:
+	}
+
+	readers := sink.demand.readers()
+	if readers == Unbounded {
+		return Unbounded
+	}
+
+	return cap(sink.ch) - len(sink.ch) + readers
+}
 
 // Close implements the Sink interface.
 func (sink *chanSink) Close() error {
@@ -59,8 +59,7 @@ func TestCloseWhilePour(t *testing.T) {
 	}()
 
 	err := sink.Pour(ctx, "test msg")
-	// TODO use exported error variables
-	r.EqualError(err, "pour to closed sink", "should return end of stream")
+	r.Equal(ErrPourToClosedSink, err, "should return pour to closed sink")
 
 	r.NoError(<-closeErr)
 
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CheckpointStore persists the offset up to which a stream has been
+// processed.
+type CheckpointStore interface {
+	// Load returns the saved offset, or false if none was saved yet.
+	Load() (offset int64, ok bool, err error)
+
+	// Save replaces the saved offset.
+	Save(offset int64) error
+}
+
+// NewMemoryCheckpoint returns a CheckpointStore that keeps the offset in
+// memory.
+func NewMemoryCheckpoint() CheckpointStore {
+	return &memoryCheckpoint{}
+}
+
+type memoryCheckpoint struct {
+	l      sync.Mutex
+	offset int64
+	ok     bool
+}
+
+// Load implements the CheckpointStore interface.
+func (cp *memoryCheckpoint) Load() (int64, bool, error) {
+	cp.l.Lock()
+	defer cp.l.Unlock()
+
+	return cp.offset, cp.ok, nil
+}
+
+// Save implements the CheckpointStore interface.
+func (cp *memoryCheckpoint) Save(offset int64) error {
+	cp.l.Lock()
+	defer cp.l.Unlock()
+
+	cp.offset, cp.ok = offset, true
+	return nil
+}
+
+// NewFileCheckpoint returns a CheckpointStore that keeps the offset in the
+// file at path. The file is replaced atomically on every Save, so a crash
+// leaves either the old or the new offset.
+func NewFileCheckpoint(path string) CheckpointStore {
+	return &fileCheckpoint{path: path}
+}
+
+type fileCheckpoint struct {
+	l    sync.Mutex
+	path string
+}
+
+// Load implements the CheckpointStore interface.
+func (cp *fileCheckpoint) Load() (int64, bool, error) {
+	cp.l.Lock()
+	defer cp.l.Unlock()
+
+	data, err := os.ReadFile(cp.path)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, errors.Wrap(err, "luigi: failed to read checkpoint")
+	}
+
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "luigi: invalid checkpoint in %s", cp.path)
+	}
+
+	return offset, true, nil
+}
+
+// Save implements the CheckpointStore interface.
+func (cp *fileCheckpoint) Save(offset int64) error {
+	cp.l.Lock()
+	defer cp.l.Unlock()
+
+	f, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "luigi: failed to create checkpoint")
+	}
+
+	_, err = f.WriteString(strconv.FormatInt(offset, 10) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), cp.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return errors.Wrap(err, "luigi: failed to write checkpoint")
+	}
+
+	return nil
+}
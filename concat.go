@@ -0,0 +1,78 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+)
+
+// Concat returns a Source that reads each of srcs until it ends, and then
+// continues with the next one. Errors other than EOS are returned as-is and
+// don't advance to the next source.
+func Concat(srcs ...Source) Source {
+	return LazyConcat(func(_ context.Context, i int) (Source, error) {
+		if i >= len(srcs) {
+			return nil, EOS{}
+		}
+
+		return srcs[i], nil
+	})
+}
+
+// SourceFactory opens the i-th source of a concatenation. It returns EOS once
+// there are no more sources.
+type SourceFactory func(ctx context.Context, i int) (Source, error)
+
+// LazyConcat is like Concat, but the sources are created by f when the
+// previous one has ended. This allows e.g. replaying old messages and only
+// then subscribing to new ones.
+func LazyConcat(f SourceFactory) Source {
+	return &concat{f: f}
+}
+
+type concat struct {
+	l   sync.Mutex
+	f   SourceFactory
+	i   int
+	cur Source
+	err error
+}
+
+// Next implements the Source interface.
+func (src *concat) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	for {
+		if src.err != nil {
+			return nil, src.err
+		}
+
+		if src.cur == nil {
+			cur, err := src.f(ctx, src.i)
+			if IsEOS(err) {
+				src.err = err
+				continue
+			} else if err != nil {
+				// failing to open a source is not final, so try again next time
+				return nil, err
+			} else if cur == nil {
+				return nil, invalidf("luigi: source factory returned no source for index %d", src.i)
+			}
+
+			src.cur = cur
+			src.i++
+		}
+
+		v, err := src.cur.Next(ctx)
+		if IsEOS(err) {
+			src.cur = nil
+			continue
+		}
+
+		return v, err
+	}
+}
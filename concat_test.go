@@ -0,0 +1,94 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcat(t *testing.T) {
+	r := require.New(t)
+
+	a := SliceSource([]interface{}{1, 2})
+	b := SliceSource([]interface{}{})
+	c := SliceSource([]interface{}{3})
+
+	r.Equal([]interface{}{1, 2, 3}, drain(t, Concat(&a, &b, &c)))
+
+	_, err := Concat().Next(context.Background())
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestConcatError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	testErr := errors.New("test error")
+	b := SliceSource([]interface{}{2})
+
+	src := Concat(failingSource(testErr, 1), &b)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	_, err = src.Next(ctx)
+	r.Equal(testErr, err)
+}
+
+func TestLazyConcat(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	live, liveSink := NewPipe(WithBuffer(1))
+
+	var opened []int
+	src := LazyConcat(func(_ context.Context, i int) (Source, error) {
+		opened = append(opened, i)
+
+		switch i {
+		case 0:
+			old := SliceSource([]interface{}{"old"})
+			return &old, nil
+		case 1:
+			return live, nil
+		default:
+			return nil, EOS{}
+		}
+	})
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal("old", v)
+	r.Equal([]int{0}, opened, "live source opened too early")
+
+	r.NoError(liveSink.Pour(ctx, "live"))
+	v, err = src.Next(ctx)
+	r.NoError(err)
+	r.Equal("live", v)
+	r.Equal([]int{0, 1}, opened)
+
+	r.NoError(liveSink.Close())
+	_, err = src.Next(ctx)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestLazyConcatNilSource(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src := LazyConcat(func(_ context.Context, i int) (Source, error) {
+		return nil, nil
+	})
+
+	_, err := src.Next(ctx)
+	r.Error(err, "a nil source should be reported")
+	_, err = src.Next(ctx)
+	r.Error(err)
+}
@@ -0,0 +1,101 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"sync/atomic"
+)
+
+// Unbounded is the demand of a consumer that accepts any number of values.
+const Unbounded = -1
+
+// Demander is implemented by Sinks that advertise how many values they are
+// ready to accept, similar to request(n) in Reactive Streams.
+type Demander interface {
+	// Demand returns the number of values the sink can currently accept
+	// without blocking, or Unbounded.
+	Demand() int
+}
+
+// Requester is implemented by Sources that can be told how many values their
+// consumer is ready to accept, so they can limit how much they produce ahead
+// of time.
+type Requester interface {
+	// Request sets the current demand of the consumer. Unlike in Reactive
+	// Streams, n is not added to earlier requests but replaces them.
+	Request(n int)
+}
+
+// Demand returns the demand advertised by sink. Sinks that don't implement
+// Demander are Unbounded, but still take values one at a time.
+func Demand(sink Sink) int {
+	if d, ok := sink.(Demander); ok {
+		return d.Demand()
+	}
+
+	return Unbounded
+}
+
+// minDemand returns the smaller of two demands, treating Unbounded as
+// infinite.
+func minDemand(a, b int) int {
+	if a == Unbounded {
+		return b
+	}
+	if b == Unbounded || a < b {
+		return a
+	}
+	return b
+}
+
+// noRequest marks a pipeDemand whose Request method hasn't been called.
+const noRequest = -2
+
+// pipeDemand tracks the readers of a pipe. Its methods are safe to call on
+// nil.
+type pipeDemand struct {
+	// waiting is the number of Next calls currently blocked
+	waiting int64
+
+	// requested is the demand set by Request, or noRequest
+	requested int64
+}
+
+func newPipeDemand() *pipeDemand {
+	return &pipeDemand{requested: noRequest}
+}
+
+func (pd *pipeDemand) startWaiting() {
+	if pd != nil {
+		atomic.AddInt64(&pd.waiting, 1)
+	}
+}
+
+func (pd *pipeDemand) doneWaiting() {
+	if pd != nil {
+		atomic.AddInt64(&pd.waiting, -1)
+	}
+}
+
+func (pd *pipeDemand) request(n int) {
+	if pd != nil {
+		atomic.StoreInt64(&pd.requested, int64(n))
+	}
+}
+
+// readers returns how many values the reading side is going to take, or
+// Unbounded. Unless the reader called Request, this is the number of Next
+// calls currently waiting.
+func (pd *pipeDemand) readers() int {
+	if pd == nil {
+		return 0
+	}
+
+	if req := atomic.LoadInt64(&pd.requested); req != noRequest {
+		return int(req)
+	}
+
+	return int(atomic.LoadInt64(&pd.waiting))
+}
@@ -0,0 +1,116 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type demandSink struct {
+	Sink
+	demand int
+}
+
+func (sink demandSink) Demand() int { return sink.demand }
+
+// waitFor polls cond until it returns true, failing the test after a second.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPipeDemand(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(3))
+	r.Equal(3, Demand(sink))
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.Equal(2, Demand(sink))
+
+	src.(Requester).Request(5)
+	r.Equal(7, Demand(sink))
+
+	src.(Requester).Request(Unbounded)
+	r.Equal(Unbounded, Demand(sink))
+
+	r.NoError(sink.Close())
+	r.Equal(0, Demand(sink))
+}
+
+func TestPipeDemandWaitingReader(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe()
+	r.Equal(0, Demand(sink))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		src.Next(ctx)
+	}()
+
+	waitFor(t, func() bool { return Demand(sink) == 1 })
+
+	r.NoError(sink.Pour(ctx, 1))
+	<-done
+	r.Equal(0, Demand(sink))
+}
+
+func TestPumpDemand(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(1))
+
+	var out []interface{}
+	dst := demandSink{Sink: NewSliceSink(&out), demand: 4}
+
+	done := make(chan error)
+	go func() {
+		done <- Pump(ctx, dst, src)
+	}()
+
+	// the demand of dst is advertised through the pipe
+	waitFor(t, func() bool { return Demand(sink) == 5 })
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Close())
+	r.NoError(<-done)
+	r.Equal([]interface{}{1}, out)
+}
+
+func TestBroadcastDemand(t *testing.T) {
+	r := require.New(t)
+
+	sink, bcst := NewBroadcast()
+	r.Equal(Unbounded, Demand(sink), "no subscribers")
+
+	var out []interface{}
+	cancel1 := bcst.Register(NewSliceSink(&out))
+	defer cancel1()
+	r.Equal(Unbounded, Demand(sink), "sinks without demand are unbounded")
+
+	cancel2 := bcst.Register(demandSink{Sink: NewSliceSink(&out), demand: 3})
+	defer cancel2()
+	cancel3 := bcst.Register(demandSink{Sink: NewSliceSink(&out), demand: 2})
+	r.Equal(2, Demand(sink))
+
+	cancel3()
+	r.Equal(3, Demand(sink))
+}
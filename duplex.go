@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+// Duplex is the interface for bidirectional streams. Next reads what the
+// other end poured, and Pour writes to the other end.
+//
+// Close and CloseWithError only close the writing side, so the other end
+// sees the end of the stream while it can still write to this end.
+type Duplex interface {
+	Source
+	Sink
+	ErrorCloser
+}
+
+// NewDuplexPipe returns both ends of a bidirectional stream. Each direction is
+// backed by a pipe created using NewPipe with the given options.
+func NewDuplexPipe(opts ...PipeOpt) (Duplex, Duplex) {
+	aSrc, bSink := NewPipe(opts...)
+	bSrc, aSink := NewPipe(opts...)
+
+	return &duplex{Source: aSrc, Sink: aSink}, &duplex{Source: bSrc, Sink: bSink}
+}
+
+type duplex struct {
+	Source
+	Sink
+}
+
+// CloseWithError implements the ErrorCloser interface.
+func (d *duplex) CloseWithError(err error) error {
+	return d.Sink.(ErrorCloser).CloseWithError(err)
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplexPipe(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	a, b := NewDuplexPipe(WithBuffer(1))
+
+	r.NoError(a.Pour(ctx, "ping"))
+	v, err := b.Next(ctx)
+	r.NoError(err)
+	r.Equal("ping", v)
+
+	r.NoError(b.Pour(ctx, "pong"))
+	v, err = a.Next(ctx)
+	r.NoError(err)
+	r.Equal("pong", v)
+
+	// half-close: a is done writing, but still reads
+	r.NoError(a.Close())
+	_, err = b.Next(ctx)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+	r.Equal(ErrPourToClosedSink, a.Pour(ctx, "too late"))
+
+	r.NoError(b.Pour(ctx, "still open"))
+	v, err = a.Next(ctx)
+	r.NoError(err)
+	r.Equal("still open", v)
+
+	testErr := errors.New("test error")
+	r.NoError(b.CloseWithError(testErr))
+	_, err = a.Next(ctx)
+	r.Equal(testErr, err)
+}
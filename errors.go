@@ -0,0 +1,80 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// These are the kinds of errors returned by luigi's streams. Use errors.Is to
+// check whether an error is of one of them.
+var (
+	// ErrClosed is the kind of errors caused by using a closed stream.
+	ErrClosed = errors.New("luigi: stream closed")
+
+	// ErrWouldBlock is the kind of errors returned by non-blocking streams
+	// that are not ready.
+	ErrWouldBlock = errors.New("luigi: operation would block")
+
+	// ErrReadOnly is the kind of errors caused by writing to something that
+	// can only be read.
+	ErrReadOnly = errors.New("luigi: read-only")
+
+	// ErrTimeout is the kind of errors caused by an operation taking too
+	// long.
+	ErrTimeout = errors.New("luigi: timeout")
+
+	// ErrTooSlow is the kind of errors caused by a consumer that can't keep
+	// up with its producer.
+	ErrTooSlow = errors.New("luigi: consumer too slow")
+
+	// ErrInvalid is the kind of errors caused by invalid arguments or
+	// options.
+	ErrInvalid = errors.New("luigi: invalid argument")
+)
+
+var (
+	// ErrPourToClosedSink is returned by Pour on a closed sink.
+	ErrPourToClosedSink = &Error{Kind: ErrClosed, Msg: "luigi: pour to closed sink"}
+
+	// ErrCloseClosedSink is returned by sinks that can only be closed once.
+	ErrCloseClosedSink = &Error{Kind: ErrClosed, Msg: "luigi: closing closed sink"}
+
+	// ErrNotReadyForWriting is returned by Pour on a non-blocking pipe that
+	// has no room for the value.
+	ErrNotReadyForWriting = &Error{Kind: ErrWouldBlock, Msg: "luigi: channel not ready for writing"}
+
+	// ErrNotReadyForReading is returned by Next on a non-blocking pipe that
+	// has no value.
+	ErrNotReadyForReading = &Error{Kind: ErrWouldBlock, Msg: "luigi: channel not ready for reading"}
+
+	// ErrReadOnlyObservable is returned by Set on observables that are
+	// updated by something else.
+	ErrReadOnlyObservable = &Error{Kind: ErrReadOnly, Msg: "luigi: read-only observable"}
+)
+
+// Error is an error of one of the kinds above. The messages of all errors
+// returned by luigi's packages start with "luigi: ", except for those of
+// invalid options, which are wrapped by the constructor that got them.
+type Error struct {
+	Kind error
+	Msg  string
+}
+
+func (err *Error) Error() string {
+	return err.Msg
+}
+
+// Is returns whether target is the kind of err.
+func (err *Error) Is(target error) bool {
+	return target == err.Kind
+}
+
+// invalidf returns an error of kind ErrInvalid with the formatted message.
+func invalidf(format string, args ...interface{}) error {
+	return &Error{Kind: ErrInvalid, Msg: fmt.Sprintf(format, args...)}
+}
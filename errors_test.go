@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKinds(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(NonBlocking())
+	_, err := src.Next(ctx)
+	r.Equal(ErrNotReadyForReading, err)
+	r.True(stderrors.Is(err, ErrWouldBlock))
+	err = sink.Pour(ctx, 1)
+	r.Equal(ErrNotReadyForWriting, err)
+	r.True(stderrors.Is(err, ErrWouldBlock))
+	r.False(stderrors.Is(err, ErrClosed))
+
+	_, sink = NewPipe(WithOverflow(OverflowFail))
+	r.True(stderrors.Is(sink.Pour(ctx, 1), ErrWouldBlock))
+
+	var out []interface{}
+	sink = NewSliceSink(&out)
+	r.NoError(sink.Close())
+	err = sink.Pour(ctx, 1)
+	r.Equal(ErrPourToClosedSink, err)
+	r.True(stderrors.Is(errors.Wrap(err, "wrapped"), ErrClosed))
+
+	var lErr *Error
+	r.True(stderrors.As(fmt.Errorf("wrapped: %w", err), &lErr))
+	r.Equal(ErrClosed, lErr.Kind)
+}
+
+func TestErrorKindsComplete(t *testing.T) {
+	r := require.New(t)
+
+	sentinels := map[error]error{
+		ErrPourToClosedSink:   ErrClosed,
+		ErrCloseClosedSink:    ErrClosed,
+		ErrHubClosed:          ErrClosed,
+		ErrBroadcastClosed:    ErrClosed,
+		ErrNotReadyForWriting: ErrWouldBlock,
+		ErrNotReadyForReading: ErrWouldBlock,
+		ErrBufferFull:         ErrWouldBlock,
+		ErrReadOnlyObservable: ErrReadOnly,
+		ErrPourTimeout:        ErrTimeout,
+		ErrLagging:            ErrTooSlow,
+		ErrHighWaterMark:      ErrTooSlow,
+		ErrSlowSubscriber:     ErrTooSlow,
+		ErrReplayLagging:      ErrTooSlow,
+	}
+	for err, kind := range sentinels {
+		r.True(stderrors.Is(err, kind), "%v should be of kind %v", err, kind)
+		r.True(strings.HasPrefix(err.Error(), "luigi: "), "%q lacks the prefix", err)
+	}
+
+	invalid := func(f func()) (err error) {
+		defer func() { err, _ = recover().(error) }()
+		f()
+		return nil
+	}
+	err := invalid(func() { NewPipe(WithOverflow(42)) })
+	r.True(stderrors.Is(err, ErrInvalid), "invalid options should be of kind ErrInvalid, got %v", err)
+	err = invalid(func() { MergeWithOpts(nil, WithMergeContext(nil)) })
+	r.True(stderrors.Is(err, ErrInvalid), "invalid options should be of kind ErrInvalid, got %v", err)
+}
+
+func TestIsEOSWrapped(t *testing.T) {
+	r := require.New(t)
+
+	r.True(IsEOS(EOS{}))
+	r.True(IsEOS(errors.Wrap(EOS{}, "pkg/errors")))
+	r.True(IsEOS(fmt.Errorf("fmt: %w", EOS{})))
+	r.False(IsEOS(stderrors.New("end of stream")))
+	r.False(IsEOS(nil))
+}
@@ -0,0 +1,229 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// ErrHubClosed is returned when using a Hub after Close.
+var ErrHubClosed = &Error{Kind: ErrClosed, Msg: "luigi: hub closed"}
+
+// Hub routes values published under a topic to the sinks registered with a
+// matching pattern.
+//
+// Topics are made of segments separated by slashes, like "feeds/alice/post".
+// A pattern matches a topic if all segments are equal, except that a "*"
+// segment matches any single segment and a final "**" segment matches any
+// number of remaining segments, including none. So "feeds/*/post" and
+// "feeds/**" both match the topic above.
+type Hub struct {
+	opts []BroadcastOpt
+
+	l      sync.Mutex
+	topics map[string]*hubTopic
+	closed bool
+}
+
+// hubTopic is the broadcast of all sinks registered with the same pattern.
+type hubTopic struct {
+	segments []string
+	sink     Sink
+	bcst     *broadcast
+	count    int
+}
+
+// NewHub returns a new Hub. The options are used for the Broadcast created
+// for every pattern.
+func NewHub(opts ...BroadcastOpt) *Hub {
+	return &Hub{
+		opts:   opts,
+		topics: make(map[string]*hubTopic),
+	}
+}
+
+// Register registers dst to receive the values published to topics matching
+// pattern. It returns an error if pattern or opts are invalid. The returned
+// func unregisters and closes dst.
+func (h *Hub) Register(pattern string, dst Sink, opts ...RegisterOpt) (func(), error) {
+	segments, err := parsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	if h.closed {
+		return nil, ErrHubClosed
+	}
+
+	t, ok := h.topics[pattern]
+	if !ok {
+		sink, bcst := NewBroadcast(h.opts...)
+		t = &hubTopic{segments: segments, sink: sink, bcst: bcst.(*broadcast)}
+	}
+
+	sub, err := t.bcst.newSubscriber(dst, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	forget := func() {
+		h.l.Lock()
+		defer h.l.Unlock()
+
+		t.count--
+		if t.count == 0 && h.topics[pattern] == t {
+			delete(h.topics, pattern)
+		}
+	}
+	// evicted sinks are already unregistered and closed
+	sub.onEvict = func() { once.Do(forget) }
+
+	cancel, err := t.bcst.add(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	h.topics[pattern] = t
+	t.count++
+
+	return func() {
+		once.Do(func() {
+			cancel()
+			forget()
+		})
+	}, nil
+}
+
+// Pour publishes v to topic.
+func (h *Hub) Pour(ctx context.Context, topic string, v interface{}) error {
+	h.l.Lock()
+	if h.closed {
+		h.l.Unlock()
+		return ErrHubClosed
+	}
+
+	var sinks []Sink
+	segments := strings.Split(topic, "/")
+	for _, t := range h.topics {
+		if matchTopic(t.segments, segments) {
+			sinks = append(sinks, t.sink)
+		}
+	}
+	h.l.Unlock()
+
+	for _, sink := range sinks {
+		err := sink.Pour(ctx, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Publisher returns a Sink that publishes the values poured into it to topic.
+// Closing it has no effect.
+func (h *Hub) Publisher(topic string) Sink {
+	return &hubPublisher{hub: h, topic: topic}
+}
+
+type hubPublisher struct {
+	hub   *Hub
+	topic string
+}
+
+// Pour implements the Sink interface.
+func (p *hubPublisher) Pour(ctx context.Context, v interface{}) error {
+	return p.hub.Pour(ctx, p.topic, v)
+}
+
+// Close implements the Sink interface.
+func (p *hubPublisher) Close() error {
+	return nil
+}
+
+// Topics returns the number of sinks registered with each pattern.
+func (h *Hub) Topics() map[string]int {
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	counts := make(map[string]int, len(h.topics))
+	for pattern, t := range h.topics {
+		counts[pattern] = t.count
+	}
+
+	return counts
+}
+
+// Subscribers returns the number of sinks that values published to topic
+// are poured into.
+func (h *Hub) Subscribers(topic string) int {
+	h.l.Lock()
+	defer h.l.Unlock()
+
+	var n int
+	segments := strings.Split(topic, "/")
+	for _, t := range h.topics {
+		if matchTopic(t.segments, segments) {
+			n += t.count
+		}
+	}
+
+	return n
+}
+
+// Close closes all registered sinks. Registering and publishing fails
+// afterwards.
+func (h *Hub) Close() error {
+	h.l.Lock()
+	topics := h.topics
+	h.topics = make(map[string]*hubTopic)
+	h.closed = true
+	h.l.Unlock()
+
+	var merr *multierror.Error
+	for _, t := range topics {
+		if err := t.sink.Close(); err != nil {
+			merr = multierror.Append(merr, err)
+		}
+	}
+
+	return merr.ErrorOrNil()
+}
+
+func parsePattern(pattern string) ([]string, error) {
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		switch {
+		case s == "":
+			return nil, invalidf("luigi: empty segment in topic pattern %q", pattern)
+		case s == "**" && i != len(segments)-1:
+			return nil, invalidf("luigi: ** not at the end of topic pattern %q", pattern)
+		}
+	}
+
+	return segments, nil
+}
+
+func matchTopic(pattern, topic []string) bool {
+	for i, p := range pattern {
+		if p == "**" {
+			return true
+		}
+		if i >= len(topic) || (p != "*" && p != topic[i]) {
+			return false
+		}
+	}
+
+	return len(pattern) == len(topic)
+}
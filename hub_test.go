@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHub(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	hub := NewHub()
+
+	var exact, wildcard, prefix []interface{}
+	cancelExact, err := hub.Register("feeds/alice/post", NewSliceSink(&exact))
+	r.NoError(err)
+	cancelWildcard, err := hub.Register("feeds/*/post", NewSliceSink(&wildcard))
+	r.NoError(err)
+	_, err = hub.Register("feeds/**", NewSliceSink(&prefix))
+	r.NoError(err)
+
+	r.NoError(hub.Pour(ctx, "feeds/alice/post", 1))
+	r.NoError(hub.Pour(ctx, "feeds/bob/post", 2))
+	r.NoError(hub.Publisher("feeds/bob/vote").Pour(ctx, 3))
+	r.NoError(hub.Pour(ctx, "feeds", 4))
+	r.NoError(hub.Pour(ctx, "other/alice/post", 5))
+
+	r.Equal([]interface{}{1}, exact)
+	r.Equal([]interface{}{1, 2}, wildcard)
+	r.Equal([]interface{}{1, 2, 3, 4}, prefix)
+
+	r.Equal(3, hub.Subscribers("feeds/alice/post"))
+	r.Equal(1, hub.Subscribers("feeds/alice"))
+	r.Equal(0, hub.Subscribers("other"))
+
+	var second []interface{}
+	cancelSecond, err := hub.Register("feeds/*/post", NewSliceSink(&second))
+	r.NoError(err)
+	r.Equal(map[string]int{"feeds/alice/post": 1, "feeds/*/post": 2, "feeds/**": 1}, hub.Topics())
+
+	cancelExact()
+	cancelWildcard()
+	cancelWildcard()
+	r.Equal(map[string]int{"feeds/*/post": 1, "feeds/**": 1}, hub.Topics(), "unused topics should be removed")
+	cancelSecond()
+	r.Equal(map[string]int{"feeds/**": 1}, hub.Topics())
+
+	r.NoError(hub.Close())
+	r.True(errors.Is(hub.Pour(ctx, "feeds", 6), ErrClosed))
+	_, err = hub.Register("feeds", NewSliceSink(&exact))
+	r.Equal(ErrHubClosed, err)
+}
+
+func TestHubInvalidPattern(t *testing.T) {
+	r := require.New(t)
+
+	hub := NewHub()
+	for _, pattern := range []string{"", "feeds//post", "feeds/**/post"} {
+		_, err := hub.Register(pattern, NewSliceSink(new([]interface{})))
+		r.Error(err, "pattern %q should be invalid", pattern)
+	}
+	r.Empty(hub.Topics())
+}
+
+func TestHubRegisterOpts(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	hub := NewHub()
+	double := func(_ context.Context, v interface{}) (interface{}, error) {
+		return 2 * v.(int), nil
+	}
+
+	var out []interface{}
+	_, err := hub.Register("numbers", NewSliceSink(&out), WithMap(double))
+	r.NoError(err)
+	r.NoError(hub.Pour(ctx, "numbers", 21))
+	r.Equal([]interface{}{42}, out)
+
+	_, err = hub.Register("keyed", NewSliceSink(&out), WithKey("k"))
+	r.Error(err, "the hub has no key func")
+	r.Equal(map[string]int{"numbers": 1}, hub.Topics())
+}
+
+func TestHubEvicted(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	hub := NewHub(WithConcurrentDelivery(1))
+
+	failErr := errors.New("sink failed")
+	closed := make(chan error, 1)
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if err != nil {
+			closed <- err
+			return nil
+		}
+		return failErr
+	}
+
+	var out []interface{}
+	_, err := hub.Register("feeds/*", NewSliceSink(&out))
+	r.NoError(err)
+	cancel, err := hub.Register("feeds/**", failing)
+	r.NoError(err)
+	r.Equal(2, hub.Subscribers("feeds/alice"))
+
+	r.NoError(hub.Pour(ctx, "feeds/alice", 1))
+	r.Equal(failErr, <-closed, "the failing sink should be evicted")
+	r.Equal(map[string]int{"feeds/*": 1}, hub.Topics(), "the topic of the evicted sink should be removed")
+	r.Equal(1, hub.Subscribers("feeds/alice"))
+
+	cancel()
+	r.Equal(map[string]int{"feeds/*": 1}, hub.Topics(), "cancelling an evicted sink should do nothing")
+}
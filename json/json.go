@@ -11,6 +11,7 @@ import (
 	"reflect"
 
 	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-luigi/typed"
 )
 
 type source struct {
@@ -53,3 +54,40 @@ func NewSink(wc io.WriteCloser) luigi.Sink {
 func (sink *sink) Pour(ctx context.Context, v interface{}) error {
 	return sink.enc.Encode(v)
 }
+
+type typedSource[T any] struct {
+	dec *json.Decoder
+}
+
+// NewTypedSource returns a new source that emits values of type T read from the Reader in JSON format.
+func NewTypedSource[T any](r io.Reader) typed.Source[T] {
+	return &typedSource[T]{
+		dec: json.NewDecoder(r),
+	}
+}
+
+func (src *typedSource[T]) Next(ctx context.Context) (T, error) {
+	var v T
+	err := src.dec.Decode(&v)
+	if err == io.EOF {
+		return v, luigi.EOS{}
+	}
+	return v, err
+}
+
+type typedSink[T any] struct {
+	io.Closer
+	enc *json.Encoder
+}
+
+// NewTypedSink returns a new sink that writes incoming values of type T to the passed WriteCloser in JSON format
+func NewTypedSink[T any](wc io.WriteCloser) typed.Sink[T] {
+	return &typedSink[T]{
+		Closer: wc,
+		enc:    json.NewEncoder(wc),
+	}
+}
+
+func (sink *typedSink[T]) Pour(ctx context.Context, v T) error {
+	return sink.enc.Encode(v)
+}
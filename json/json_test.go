@@ -193,3 +193,37 @@ func TestSink(t *testing.T) {
 		test(tc)
 	}
 }
+
+func TestTypedRoundtrip(t *testing.T) {
+	type jsonType struct {
+		K      string `json:"k"`
+		Answer int    `json:"answer"`
+	}
+
+	ctx := context.Background()
+	values := []jsonType{{K: "v", Answer: 42}, {K: "not v", Answer: 23}}
+
+	var buf bytes.Buffer
+	sink := NewTypedSink[jsonType](writeCloser{&buf})
+	for i, v := range values {
+		if err := sink.Pour(ctx, v); err != nil {
+			t.Fatalf("unexpected error pouring value %d: %s", i, err)
+		}
+	}
+
+	src := NewTypedSource[jsonType](&buf)
+	for i, exp := range values {
+		v, err := src.Next(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error reading value %d: %s", i, err)
+		}
+
+		if v != exp {
+			t.Errorf("expected %#v, got %#v", exp, v)
+		}
+	}
+
+	if _, err := src.Next(ctx); !luigi.IsEOS(err) {
+		t.Errorf("expected end of stream, got %v", err)
+	}
+}
@@ -21,3 +21,26 @@ func Expvar(o luigi.Observable) expvar.Var {
 		return v
 	})
 }
+
+// PipeStats returns an expvar.Var publishing the stats of a pipe.
+func PipeStats(r luigi.StatsReporter) expvar.Var {
+	return expvar.Func(func() interface{} {
+		stats := r.Stats()
+
+		m := map[string]interface{}{
+			"len":           stats.Len,
+			"cap":           stats.Cap,
+			"poured":        stats.Poured,
+			"read":          stats.Read,
+			"dropped":       stats.Dropped,
+			"blockedPourNs": int64(stats.BlockedPour),
+			"closed":        stats.Closed,
+		}
+
+		if stats.CloseErr != nil && !luigi.IsEOS(stats.CloseErr) {
+			m["closeErr"] = stats.CloseErr.Error()
+		}
+
+		return m
+	})
+}
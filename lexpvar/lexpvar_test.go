@@ -5,6 +5,7 @@
 package lexpvar
 
 import (
+	"context"
 	"encoding/json"
 	"expvar"
 	"fmt"
@@ -52,3 +53,35 @@ func TestExpvar(t *testing.T) {
 
 	i++
 }
+
+func TestPipeStats(t *testing.T) {
+	a := assert.New(t)
+	src, sink := luigi.NewPipe(luigi.WithBuffer(4))
+	k := fmt.Sprintf("pipe_%d", i)
+	expvar.Publish(k, PipeStats(sink.(luigi.StatsReporter)))
+
+	a.NoError(sink.Pour(context.Background(), 1))
+	a.NoError(sink.Pour(context.Background(), 2))
+	_, err := src.Next(context.Background())
+	a.NoError(err)
+
+	rr := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/debug/vars", nil)
+	a.NoError(err, "error making request")
+
+	expvar.Handler().ServeHTTP(rr, req)
+
+	var d map[string]interface{}
+	err = json.NewDecoder(rr.Body).Decode(&d)
+	a.NoError(err, "error decoding body")
+
+	stats, ok := d[k].(map[string]interface{})
+	a.True(ok)
+	a.Equal(1.0, stats["len"])
+	a.Equal(4.0, stats["cap"])
+	a.Equal(2.0, stats["poured"])
+	a.Equal(1.0, stats["read"])
+	a.Equal(false, stats["closed"])
+
+	i++
+}
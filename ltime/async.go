@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// asyncSink hands poured values to a goroutine that decides when to pass
+// them on to dst. Errors returned by dst are reported by the next call to
+// Pour or Close.
+type asyncSink struct {
+	dst luigi.Sink
+
+	in   chan interface{}
+	ack  chan struct{}
+	stop chan bool
+	done chan struct{}
+
+	l      sync.Mutex
+	err    error
+	closed bool
+}
+
+// newAsyncSink starts run in a new goroutine. run receives values from in
+// and has to call acknowledge once it handled each. It has to return once it
+// receives from stop, flushing pending values if it receives true.
+func newAsyncSink(dst luigi.Sink, run func(s *asyncSink)) *asyncSink {
+	s := &asyncSink{
+		dst:  dst,
+		in:   make(chan interface{}),
+		ack:  make(chan struct{}),
+		stop: make(chan bool),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+		run(s)
+	}()
+
+	return s
+}
+
+// acknowledge lets Pour return once a value was handled, so timers set for
+// it are in place.
+func (s *asyncSink) acknowledge() {
+	s.ack <- struct{}{}
+}
+
+// pour passes v on to dst, recording errors.
+func (s *asyncSink) pour(v interface{}) {
+	err := s.dst.Pour(context.Background(), v)
+	if err != nil {
+		s.l.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.l.Unlock()
+	}
+}
+
+func (s *asyncSink) state() (bool, error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.closed, s.err
+}
+
+// Pour implements the luigi.Sink interface.
+func (s *asyncSink) Pour(ctx context.Context, v interface{}) error {
+	closed, err := s.state()
+	if closed {
+		return luigi.ErrPourToClosedSink
+	} else if err != nil {
+		return err
+	}
+
+	select {
+	case s.in <- v:
+		<-s.ack
+		return nil
+	case <-s.done:
+		return luigi.ErrPourToClosedSink
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close implements the luigi.Sink interface. Pending values are passed on
+// before dst is closed.
+func (s *asyncSink) Close() error {
+	return s.closeWith(true, luigi.EOS{})
+}
+
+// CloseWithError implements the luigi.ErrorCloser interface. Pending values
+// are dropped.
+func (s *asyncSink) CloseWithError(err error) error {
+	return s.closeWith(false, err)
+}
+
+func (s *asyncSink) closeWith(flush bool, err error) error {
+	s.l.Lock()
+	if s.closed {
+		s.l.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.l.Unlock()
+
+	s.stop <- flush
+	<-s.done
+
+	var closeErr error
+	if ec, ok := s.dst.(luigi.ErrorCloser); ok && !luigi.IsEOS(err) {
+		closeErr = ec.CloseWithError(err)
+	} else {
+		closeErr = s.dst.Close()
+	}
+
+	_, pourErr := s.state()
+	if pourErr != nil {
+		return pourErr
+	}
+
+	return closeErr
+}
+
+// pumpThrough returns a Source that returns the values of src after passing
+// them through the sink returned by wrap. src is read in a new goroutine
+// until it ends.
+func pumpThrough(src luigi.Source, wrap func(luigi.Sink) luigi.Sink) luigi.Source {
+	out, sink := luigi.NewPipe()
+	dst := wrap(sink)
+
+	go func() {
+		err := luigi.Pump(context.Background(), dst, src)
+		if err != nil {
+			dst.(luigi.ErrorCloser).CloseWithError(err)
+		} else {
+			dst.Close()
+		}
+	}()
+
+	return out
+}
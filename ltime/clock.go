@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package ltime provides time-based stream operators. All of them take a
+// Clock, so they can be tested using a FakeClock instead of sleeping.
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// Clock is the source of time used by the operators in this package.
+type Clock = luigi.Clock
+
+// Timer is a single event in the future, like time.Timer.
+type Timer = luigi.Timer
+
+// RealClock is the Clock backed by the time package.
+var RealClock = luigi.RealClock
+
+// FakeClock is a Clock whose time only changes when Advance is called.
+type FakeClock struct {
+	l       sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer
+	changed chan struct{}
+}
+
+// NewFakeClock returns a FakeClock set to start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{
+		now:     start,
+		changed: make(chan struct{}),
+	}
+}
+
+// Now implements the Clock interface.
+func (clk *FakeClock) Now() time.Time {
+	clk.l.Lock()
+	defer clk.l.Unlock()
+
+	return clk.now
+}
+
+// NewTimer implements the Clock interface.
+func (clk *FakeClock) NewTimer(d time.Duration) Timer {
+	clk.l.Lock()
+	defer clk.l.Unlock()
+
+	t := &fakeTimer{
+		clk:      clk,
+		deadline: clk.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+
+	if d <= 0 {
+		t.c <- clk.now
+		return t
+	}
+
+	clk.timers = append(clk.timers, t)
+	clk.notify()
+	return t
+}
+
+// Advance moves the clock forward by d and fires all timers that are due,
+// in order of their deadlines.
+func (clk *FakeClock) Advance(d time.Duration) {
+	clk.l.Lock()
+	defer clk.l.Unlock()
+
+	clk.now = clk.now.Add(d)
+
+	sort.SliceStable(clk.timers, func(i, j int) bool {
+		return clk.timers[i].deadline.Before(clk.timers[j].deadline)
+	})
+
+	var pending []*fakeTimer
+	for _, t := range clk.timers {
+		if t.deadline.After(clk.now) {
+			pending = append(pending, t)
+			continue
+		}
+
+		t.c <- t.deadline
+	}
+
+	clk.timers = pending
+	clk.notify()
+}
+
+// Timers returns the number of timers that have not fired yet.
+func (clk *FakeClock) Timers() int {
+	clk.l.Lock()
+	defer clk.l.Unlock()
+
+	return len(clk.timers)
+}
+
+// BlockUntil waits until n timers are waiting to fire. This is useful to
+// make sure an operator running in another goroutine has set its timer
+// before calling Advance.
+func (clk *FakeClock) BlockUntil(n int) {
+	clk.l.Lock()
+	for len(clk.timers) != n {
+		changed := clk.changed
+		clk.l.Unlock()
+		<-changed
+		clk.l.Lock()
+	}
+	clk.l.Unlock()
+}
+
+// notify wakes up BlockUntil calls. It must be called with the lock held.
+func (clk *FakeClock) notify() {
+	close(clk.changed)
+	clk.changed = make(chan struct{})
+}
+
+type fakeTimer struct {
+	clk      *FakeClock
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clk.l.Lock()
+	defer t.clk.l.Unlock()
+
+	for i, other := range t.clk.timers {
+		if other == t {
+			t.clk.timers = append(t.clk.timers[:i], t.clk.timers[i+1:]...)
+			t.clk.notify()
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// chanSink returns a Sink sending all values on the returned channel. The
+// channel is closed when the sink is.
+func chanSink() (luigi.Sink, <-chan interface{}) {
+	ch := make(chan interface{}, 16)
+	return luigi.FuncSink(func(ctx context.Context, v interface{}, err error) error {
+		if err != nil {
+			close(ch)
+			return nil
+		}
+
+		ch <- v
+		return nil
+	}), ch
+}
+
+func TestFakeClock(t *testing.T) {
+	r := require.New(t)
+
+	clk := NewFakeClock(epoch)
+	r.Equal(epoch, clk.Now())
+
+	t1 := clk.NewTimer(2 * time.Second)
+	t2 := clk.NewTimer(time.Second)
+	t3 := clk.NewTimer(3 * time.Second)
+	r.Equal(3, clk.Timers())
+
+	r.True(t3.Stop())
+	r.False(t3.Stop())
+
+	clk.Advance(2 * time.Second)
+	r.Equal(epoch.Add(2*time.Second), clk.Now())
+	r.Equal(epoch.Add(time.Second), <-t2.C())
+	r.Equal(epoch.Add(2*time.Second), <-t1.C())
+	r.Equal(0, clk.Timers())
+	r.False(t1.Stop())
+
+	select {
+	case <-t3.C():
+		t.Error("stopped timer fired")
+	default:
+	}
+}
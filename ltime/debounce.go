@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// SinkDebounce returns a Sink that only passes a value on to sink once d has
+// passed without another value being poured. Closing it passes on the last
+// pending value, if any.
+func SinkDebounce(sink luigi.Sink, clk Clock, d time.Duration) luigi.Sink {
+	return newAsyncSink(sink, func(s *asyncSink) {
+		var (
+			timer   Timer
+			timerC  <-chan time.Time
+			pending interface{}
+			has     bool
+		)
+
+		for {
+			select {
+			case v := <-s.in:
+				pending, has = v, true
+
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = clk.NewTimer(d)
+				timerC = timer.C()
+				s.acknowledge()
+
+			case <-timerC:
+				timer, timerC = nil, nil
+				s.pour(pending)
+				pending, has = nil, false
+
+			case flush := <-s.stop:
+				if timer != nil {
+					timer.Stop()
+				}
+				if flush && has {
+					s.pour(pending)
+				}
+				return
+			}
+		}
+	})
+}
+
+// SourceDebounce returns a Source that only returns a value of src once d
+// has passed without src producing another one. src is read in a new
+// goroutine until it ends.
+func SourceDebounce(src luigi.Source, clk Clock, d time.Duration) luigi.Source {
+	return pumpThrough(src, func(sink luigi.Sink) luigi.Sink {
+		return SinkDebounce(sink, clk, d)
+	})
+}
@@ -0,0 +1,89 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebounce(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	dst, out := chanSink()
+	sink := SinkDebounce(dst, clk, time.Second)
+
+	r.NoError(sink.Pour(ctx, 1))
+	clk.Advance(500 * time.Millisecond)
+	r.NoError(sink.Pour(ctx, 2))
+	clk.Advance(500 * time.Millisecond)
+	r.Empty(out, "1 should have been replaced by 2")
+
+	clk.Advance(500 * time.Millisecond)
+	r.Equal(2, <-out)
+
+	r.NoError(sink.Pour(ctx, 3))
+	r.NoError(sink.Close())
+	r.Equal(3, <-out, "pending value should be flushed")
+	_, open := <-out
+	r.False(open)
+
+	r.Equal(luigi.ErrPourToClosedSink, sink.Pour(ctx, 4))
+}
+
+func TestSourceDebounce(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	in, inSink := luigi.NewPipe()
+	src := SourceDebounce(in, clk, time.Second)
+
+	r.NoError(inSink.Pour(ctx, 1))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	r.NoError(inSink.Close())
+	_, err = src.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestSample(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	dst, out := chanSink()
+	sink := SinkSample(dst, clk, time.Second)
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(2, <-out)
+
+	// nothing new, nothing sampled
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	r.NoError(sink.Pour(ctx, 3))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(3, <-out)
+
+	r.NoError(sink.Close())
+	_, open := <-out
+	r.False(open)
+}
@@ -0,0 +1,103 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// sleep waits until clk reaches t or ctx is done.
+func sleep(ctx context.Context, clk Clock, t time.Time) error {
+	d := t.Sub(clk.Now())
+	if d <= 0 {
+		return nil
+	}
+
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SinkDelay returns a Sink that waits d before passing each value on to sink.
+func SinkDelay(sink luigi.Sink, clk Clock, d time.Duration) luigi.Sink {
+	return &sinkDelay{
+		Sink: sink,
+		clk:  clk,
+		d:    d,
+	}
+}
+
+type sinkDelay struct {
+	luigi.Sink
+	clk Clock
+	d   time.Duration
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink *sinkDelay) Pour(ctx context.Context, v interface{}) error {
+	err := sleep(ctx, sink.clk, sink.clk.Now().Add(sink.d))
+	if err != nil {
+		return err
+	}
+
+	return sink.Sink.Pour(ctx, v)
+}
+
+// SourceDelay returns a Source that returns each value of src d after it was
+// read. If a call to Next is aborted while waiting, the value is returned by
+// the next call.
+func SourceDelay(src luigi.Source, clk Clock, d time.Duration) luigi.Source {
+	return &srcDelay{
+		Source: src,
+		clk:    clk,
+		d:      d,
+	}
+}
+
+type srcDelay struct {
+	luigi.Source
+	clk Clock
+	d   time.Duration
+
+	l          sync.Mutex
+	pending    interface{}
+	hasPending bool
+	due        time.Time
+}
+
+// Next implements the luigi.Source interface.
+func (src *srcDelay) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	if !src.hasPending {
+		v, err := src.Source.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		src.pending, src.hasPending = v, true
+		src.due = src.clk.Now().Add(src.d)
+	}
+
+	err := sleep(ctx, src.clk, src.due)
+	if err != nil {
+		return nil, err
+	}
+
+	v := src.pending
+	src.pending, src.hasPending = nil, false
+	return v, nil
+}
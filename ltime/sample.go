@@ -0,0 +1,55 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// SinkSample returns a Sink that passes on the latest value poured into it
+// every d, unless nothing was poured since the last time. Closing it passes
+// on the last pending value, if any.
+func SinkSample(sink luigi.Sink, clk Clock, d time.Duration) luigi.Sink {
+	return newAsyncSink(sink, func(s *asyncSink) {
+		var (
+			timer  = clk.NewTimer(d)
+			latest interface{}
+			has    bool
+		)
+
+		for {
+			select {
+			case v := <-s.in:
+				latest, has = v, true
+				s.acknowledge()
+
+			case <-timer.C():
+				if has {
+					s.pour(latest)
+					latest, has = nil, false
+				}
+				timer = clk.NewTimer(d)
+
+			case flush := <-s.stop:
+				timer.Stop()
+				if flush && has {
+					s.pour(latest)
+				}
+				return
+			}
+		}
+	})
+}
+
+// SourceSample returns a Source that returns the latest value of src every
+// d, unless src produced nothing since the last time. src is read in a new
+// goroutine until it ends.
+func SourceSample(src luigi.Source, clk Clock, d time.Duration) luigi.Source {
+	return pumpThrough(src, func(sink luigi.Sink) luigi.Sink {
+		return SinkSample(sink, clk, d)
+	})
+}
@@ -0,0 +1,88 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// throttle lets through at most one value per interval.
+type throttle struct {
+	clk Clock
+	d   time.Duration
+
+	l       sync.Mutex
+	last    time.Time
+	emitted bool
+}
+
+// pass returns whether a value arriving now may pass.
+func (t *throttle) pass() bool {
+	t.l.Lock()
+	defer t.l.Unlock()
+
+	now := t.clk.Now()
+	if t.emitted && now.Sub(t.last) < t.d {
+		return false
+	}
+
+	t.last, t.emitted = now, true
+	return true
+}
+
+// SinkThrottle returns a Sink that passes a value on to sink and then drops
+// all values poured within d after it.
+func SinkThrottle(sink luigi.Sink, clk Clock, d time.Duration) luigi.Sink {
+	return &sinkThrottle{
+		Sink:     sink,
+		throttle: throttle{clk: clk, d: d},
+	}
+}
+
+type sinkThrottle struct {
+	luigi.Sink
+	throttle
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink *sinkThrottle) Pour(ctx context.Context, v interface{}) error {
+	if !sink.pass() {
+		return nil
+	}
+
+	return sink.Sink.Pour(ctx, v)
+}
+
+// SourceThrottle returns a Source that returns a value of src and then skips
+// all values read within d after it.
+func SourceThrottle(src luigi.Source, clk Clock, d time.Duration) luigi.Source {
+	return &srcThrottle{
+		Source:   src,
+		throttle: throttle{clk: clk, d: d},
+	}
+}
+
+type srcThrottle struct {
+	luigi.Source
+	throttle
+}
+
+// Next implements the luigi.Source interface.
+func (src *srcThrottle) Next(ctx context.Context) (interface{}, error) {
+	for {
+		v, err := src.Source.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if src.pass() {
+			return v, nil
+		}
+	}
+}
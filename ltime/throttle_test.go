@@ -0,0 +1,122 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+
+	// one value per second
+	var i int
+	src := luigi.FuncSource(func(ctx context.Context) (interface{}, error) {
+		if i == 10 {
+			return nil, luigi.EOS{}
+		}
+		if i > 0 {
+			clk.Advance(time.Second)
+		}
+		i++
+		return i - 1, nil
+	})
+
+	var out []interface{}
+	err := luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceThrottle(src, clk, 3*time.Second))
+	r.NoError(err)
+	r.Equal([]interface{}{0, 3, 6, 9}, out)
+
+	out = nil
+	sink := SinkThrottle(luigi.NewSliceSink(&out), clk, 3*time.Second)
+	for i := 0; i < 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+		clk.Advance(time.Second)
+	}
+	r.Equal([]interface{}{0, 3}, out)
+}
+
+func TestDelay(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+
+	var out []interface{}
+	sink := SinkDelay(luigi.NewSliceSink(&out), clk, time.Second)
+
+	done := make(chan error)
+	go func() { done <- sink.Pour(ctx, 1) }()
+
+	clk.BlockUntil(1)
+	r.Empty(out)
+	clk.Advance(time.Second)
+	r.NoError(<-done)
+	r.Equal([]interface{}{1}, out)
+
+	in := luigi.SliceSource([]interface{}{2})
+	src := SourceDelay(&in, clk, time.Second)
+
+	// an aborted call keeps the value
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := src.Next(cctx)
+	r.Equal(context.Canceled, err)
+
+	vs := make(chan interface{})
+	go func() {
+		v, _ := src.Next(ctx)
+		vs <- v
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(2, <-vs)
+}
+
+func TestTimeout(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	in, inSink := luigi.NewPipe(luigi.WithBuffer(1))
+	src := SourceTimeout(in, clk, time.Second)
+
+	r.NoError(inSink.Pour(ctx, 1))
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+	r.Equal(0, clk.Timers())
+
+	errs := make(chan error)
+	go func() {
+		_, err := src.Next(ctx)
+		errs <- err
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(ErrTimeout, <-errs)
+
+	// a full pipe makes the sink time out
+	r.NoError(inSink.Pour(ctx, 2))
+	sink := SinkTimeout(inSink, clk, time.Second)
+	go func() { errs <- sink.Pour(ctx, 3) }()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	err = <-errs
+	r.Equal(ErrTimeout, err)
+	r.True(errors.Is(err, luigi.ErrTimeout), "timeouts should be of kind luigi.ErrTimeout")
+}
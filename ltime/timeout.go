@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// ErrTimeout is returned by operators created using SourceTimeout and
+// SinkTimeout when the wrapped call took too long.
+var ErrTimeout = &luigi.Error{Kind: luigi.ErrTimeout, Msg: "luigi: timed out"}
+
+// withTimeout calls f with a context that is cancelled after d. If f fails
+// after that happened, ErrTimeout is returned.
+func withTimeout(ctx context.Context, clk Clock, d time.Duration, f func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	var timedOut int32
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-timer.C():
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	err := f(ctx)
+	if err != nil && atomic.LoadInt32(&timedOut) == 1 {
+		return ErrTimeout
+	}
+
+	return err
+}
+
+// SinkTimeout returns a Sink that fails with ErrTimeout if passing a value on
+// to sink takes longer than d. sink has to respect context cancellation.
+func SinkTimeout(sink luigi.Sink, clk Clock, d time.Duration) luigi.Sink {
+	return &sinkTimeout{
+		Sink: sink,
+		clk:  clk,
+		d:    d,
+	}
+}
+
+type sinkTimeout struct {
+	luigi.Sink
+	clk Clock
+	d   time.Duration
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink *sinkTimeout) Pour(ctx context.Context, v interface{}) error {
+	return withTimeout(ctx, sink.clk, sink.d, func(ctx context.Context) error {
+		return sink.Sink.Pour(ctx, v)
+	})
+}
+
+// SourceTimeout returns a Source that fails with ErrTimeout if src takes
+// longer than d to return a value. src has to respect context cancellation.
+func SourceTimeout(src luigi.Source, clk Clock, d time.Duration) luigi.Source {
+	return &srcTimeout{
+		Source: src,
+		clk:    clk,
+		d:      d,
+	}
+}
+
+type srcTimeout struct {
+	luigi.Source
+	clk Clock
+	d   time.Duration
+}
+
+// Next implements the luigi.Source interface.
+func (src *srcTimeout) Next(ctx context.Context) (v interface{}, err error) {
+	err = withTimeout(ctx, src.clk, src.d, func(ctx context.Context) error {
+		v, err = src.Source.Next(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,337 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-luigi/mfr"
+)
+
+// WindowFunc turns the values of a window into the value emitted for it. The
+// window operators emit the []interface{} itself if it is nil.
+type WindowFunc func(ctx context.Context, vs []interface{}) (interface{}, error)
+
+// Reduce returns a WindowFunc that emits the values of a window reduced using
+// f. The accumulator starts out as nil for each window.
+func Reduce(f mfr.ReduceFunc) WindowFunc {
+	return func(ctx context.Context, vs []interface{}) (interface{}, error) {
+		var (
+			acc interface{}
+			err error
+		)
+
+		for _, v := range vs {
+			acc, err = f(ctx, acc, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return acc, nil
+	}
+}
+
+// apply returns what f emits for vs.
+func (f WindowFunc) apply(ctx context.Context, vs []interface{}) (interface{}, error) {
+	if f == nil {
+		return vs, nil
+	}
+
+	return f(ctx, vs)
+}
+
+// emit passes what f emits for vs on to dst, recording errors.
+func (s *asyncSink) emit(f WindowFunc, vs []interface{}) {
+	v, err := f.apply(context.Background(), vs)
+	if err != nil {
+		s.l.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.l.Unlock()
+		return
+	}
+
+	s.pour(v)
+}
+
+// SinkBuffer returns a Sink that collects values into windows of n values
+// and passes on what f emits for each. Closing it emits the incomplete
+// window, if any.
+func SinkBuffer(sink luigi.Sink, n int, f WindowFunc) luigi.Sink {
+	if n < 1 {
+		n = 1
+	}
+
+	return &sinkBuffer{
+		Sink: sink,
+		n:    n,
+		f:    f,
+	}
+}
+
+type sinkBuffer struct {
+	luigi.Sink
+	n int
+	f WindowFunc
+
+	l  sync.Mutex
+	vs []interface{}
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink *sinkBuffer) Pour(ctx context.Context, v interface{}) error {
+	sink.l.Lock()
+	defer sink.l.Unlock()
+
+	sink.vs = append(sink.vs, v)
+	if len(sink.vs) < sink.n {
+		return nil
+	}
+
+	vs := sink.vs
+	sink.vs = nil
+
+	out, err := sink.f.apply(ctx, vs)
+	if err != nil {
+		return err
+	}
+
+	return sink.Sink.Pour(ctx, out)
+}
+
+// Close implements the luigi.Sink interface.
+func (sink *sinkBuffer) Close() error {
+	sink.l.Lock()
+	vs := sink.vs
+	sink.vs = nil
+	sink.l.Unlock()
+
+	if len(vs) > 0 {
+		out, err := sink.f.apply(context.Background(), vs)
+		if err != nil {
+			return err
+		}
+
+		err = sink.Sink.Pour(context.Background(), out)
+		if err != nil {
+			return err
+		}
+	}
+
+	return sink.Sink.Close()
+}
+
+// CloseWithError implements the luigi.ErrorCloser interface. The incomplete
+// window is dropped.
+func (sink *sinkBuffer) CloseWithError(err error) error {
+	if ec, ok := sink.Sink.(luigi.ErrorCloser); ok {
+		return ec.CloseWithError(err)
+	}
+
+	return sink.Sink.Close()
+}
+
+// SourceBuffer returns a Source that reads windows of n values from src and
+// returns what f emits for each. When src ends, the incomplete window is
+// returned before the end of the stream.
+func SourceBuffer(src luigi.Source, n int, f WindowFunc) luigi.Source {
+	if n < 1 {
+		n = 1
+	}
+
+	return &srcBuffer{
+		Source: src,
+		n:      n,
+		f:      f,
+	}
+}
+
+type srcBuffer struct {
+	luigi.Source
+	n int
+	f WindowFunc
+
+	l sync.Mutex
+
+	// vs holds the values of an incomplete window, so they are not lost
+	// when a call to Next is aborted
+	vs  []interface{}
+	err error
+}
+
+// Next implements the luigi.Source interface.
+func (src *srcBuffer) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	if src.err != nil {
+		return nil, src.err
+	}
+
+	for len(src.vs) < src.n {
+		v, err := src.Source.Next(ctx)
+		if luigi.IsEOS(err) && len(src.vs) > 0 {
+			src.err = err
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		src.vs = append(src.vs, v)
+	}
+
+	vs := src.vs
+	src.vs = nil
+
+	return src.f.apply(ctx, vs)
+}
+
+// SinkTumblingWindow returns a Sink that collects the values poured in each
+// consecutive interval of length d and passes on what f emits for each
+// non-empty window. Closing it emits the current window, if it isn't empty.
+func SinkTumblingWindow(sink luigi.Sink, clk Clock, d time.Duration, f WindowFunc) luigi.Sink {
+	return newAsyncSink(sink, func(s *asyncSink) {
+		var (
+			timer = clk.NewTimer(d)
+			vs    []interface{}
+		)
+
+		for {
+			select {
+			case v := <-s.in:
+				vs = append(vs, v)
+				s.acknowledge()
+
+			case <-timer.C():
+				if len(vs) > 0 {
+					s.emit(f, vs)
+					vs = nil
+				}
+				timer = clk.NewTimer(d)
+
+			case flush := <-s.stop:
+				timer.Stop()
+				if flush && len(vs) > 0 {
+					s.emit(f, vs)
+				}
+				return
+			}
+		}
+	})
+}
+
+// SourceTumblingWindow is the Source variant of SinkTumblingWindow. src is
+// read in a new goroutine until it ends.
+func SourceTumblingWindow(src luigi.Source, clk Clock, d time.Duration, f WindowFunc) luigi.Source {
+	return pumpThrough(src, func(sink luigi.Sink) luigi.Sink {
+		return SinkTumblingWindow(sink, clk, d, f)
+	})
+}
+
+type timedValue struct {
+	t time.Time
+	v interface{}
+}
+
+// SinkSlidingWindow returns a Sink that passes on what f emits for the
+// values poured within the last size every interval, unless there were none.
+// Closing it doesn't emit another window.
+func SinkSlidingWindow(sink luigi.Sink, clk Clock, size, every time.Duration, f WindowFunc) luigi.Sink {
+	return newAsyncSink(sink, func(s *asyncSink) {
+		var (
+			timer = clk.NewTimer(every)
+			tvs   []timedValue
+		)
+
+		for {
+			select {
+			case v := <-s.in:
+				tvs = append(tvs, timedValue{clk.Now(), v})
+				s.acknowledge()
+
+			case now := <-timer.C():
+				start := now.Add(-size)
+				for len(tvs) > 0 && !tvs[0].t.After(start) {
+					tvs = tvs[1:]
+				}
+
+				if len(tvs) > 0 {
+					vs := make([]interface{}, len(tvs))
+					for i, tv := range tvs {
+						vs[i] = tv.v
+					}
+					s.emit(f, vs)
+				}
+				timer = clk.NewTimer(every)
+
+			case <-s.stop:
+				timer.Stop()
+				return
+			}
+		}
+	})
+}
+
+// SourceSlidingWindow is the Source variant of SinkSlidingWindow. src is
+// read in a new goroutine until it ends.
+func SourceSlidingWindow(src luigi.Source, clk Clock, size, every time.Duration, f WindowFunc) luigi.Source {
+	return pumpThrough(src, func(sink luigi.Sink) luigi.Sink {
+		return SinkSlidingWindow(sink, clk, size, every, f)
+	})
+}
+
+// SinkSessionWindow returns a Sink that collects values until no value was
+// poured for the duration gap, and then passes on what f emits for them.
+// Closing it emits the current session, if any.
+func SinkSessionWindow(sink luigi.Sink, clk Clock, gap time.Duration, f WindowFunc) luigi.Sink {
+	return newAsyncSink(sink, func(s *asyncSink) {
+		var (
+			timer  Timer
+			timerC <-chan time.Time
+			vs     []interface{}
+		)
+
+		for {
+			select {
+			case v := <-s.in:
+				vs = append(vs, v)
+
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = clk.NewTimer(gap)
+				timerC = timer.C()
+				s.acknowledge()
+
+			case <-timerC:
+				timer, timerC = nil, nil
+				s.emit(f, vs)
+				vs = nil
+
+			case flush := <-s.stop:
+				if timer != nil {
+					timer.Stop()
+				}
+				if flush && len(vs) > 0 {
+					s.emit(f, vs)
+				}
+				return
+			}
+		}
+	})
+}
+
+// SourceSessionWindow is the Source variant of SinkSessionWindow. src is
+// read in a new goroutine until it ends.
+func SourceSessionWindow(src luigi.Source, clk Clock, gap time.Duration, f WindowFunc) luigi.Source {
+	return pumpThrough(src, func(sink luigi.Sink) luigi.Sink {
+		return SinkSessionWindow(sink, clk, gap, f)
+	})
+}
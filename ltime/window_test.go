@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package ltime // import "github.com/ssbc/go-luigi/ltime"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func sum(_ context.Context, acc, v interface{}) (interface{}, error) {
+	if acc == nil {
+		acc = 0
+	}
+	return acc.(int) + v.(int), nil
+}
+
+func TestBuffer(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in := luigi.SliceSource([]interface{}{1, 2, 3, 4, 5})
+	var out []interface{}
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceBuffer(&in, 2, nil)))
+	r.Equal([]interface{}{
+		[]interface{}{1, 2},
+		[]interface{}{3, 4},
+		[]interface{}{5},
+	}, out)
+
+	out = nil
+	sink := SinkBuffer(luigi.NewSliceSink(&out), 2, Reduce(sum))
+	for i := 1; i <= 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+	r.Equal([]interface{}{3, 7}, out)
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{3, 7, 5}, out)
+}
+
+func TestTumblingWindow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	dst, out := chanSink()
+	sink := SinkTumblingWindow(dst, clk, time.Second, nil)
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal([]interface{}{1, 2}, <-out)
+
+	r.NoError(sink.Pour(ctx, 3))
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{3}, <-out)
+	_, open := <-out
+	r.False(open)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	dst, out := chanSink()
+	sink := SinkSlidingWindow(dst, clk, 3*time.Second, time.Second, Reduce(sum))
+
+	// t=0
+	r.NoError(sink.Pour(ctx, 1))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(1, <-out)
+
+	// t=1
+	r.NoError(sink.Pour(ctx, 2))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(3, <-out, "window should hold 1 and 2")
+
+	// t=2
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	r.Equal(2, <-out, "1 should have left the window")
+
+	r.NoError(sink.Close())
+	_, open := <-out
+	r.False(open)
+}
+
+func TestSessionWindow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	dst, out := chanSink()
+	sink := SinkSessionWindow(dst, clk, time.Second, nil)
+
+	r.NoError(sink.Pour(ctx, 1))
+	clk.Advance(500 * time.Millisecond)
+	r.NoError(sink.Pour(ctx, 2))
+	clk.Advance(time.Second)
+	r.Equal([]interface{}{1, 2}, <-out)
+
+	r.NoError(sink.Pour(ctx, 3))
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{3}, <-out)
+}
+
+func TestSourceSessionWindow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := NewFakeClock(epoch)
+	in, inSink := luigi.NewPipe()
+	src := SourceSessionWindow(in, clk, time.Second, Reduce(sum))
+
+	r.NoError(inSink.Pour(ctx, 1))
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	r.NoError(inSink.Pour(ctx, 2))
+	r.NoError(inSink.Close())
+	v, err = src.Next(ctx)
+	r.NoError(err)
+	r.Equal(2, v, "session is flushed when the source ends")
+
+	_, err = src.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+}
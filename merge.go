@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+type mergeOpts struct {
+	ctx           context.Context
+	collectErrors bool
+}
+
+// MergeOpt configures the behavior of MergeWithOpts
+type MergeOpt func(*mergeOpts) error
+
+// WithMergeContext stops reading from the inputs once ctx is cancelled. The
+// merged source is then closed with the context's error.
+func WithMergeContext(ctx context.Context) MergeOpt {
+	return MergeOpt(func(opts *mergeOpts) error {
+		if ctx == nil {
+			return invalidf("nil context")
+		}
+
+		opts.ctx = ctx
+		return nil
+	})
+}
+
+// CollectErrors keeps reading from the other inputs when one of them fails.
+// Once all are done, the merged source is closed with a *multierror.Error
+// holding every error.
+func CollectErrors() MergeOpt {
+	return MergeOpt(func(opts *mergeOpts) error {
+		opts.collectErrors = true
+		return nil
+	})
+}
+
+// Merge returns a Source that interleaves the values of all srcs as they
+// arrive. It ends once every input has ended. If an input fails, the others
+// are abandoned and the merged source returns that error after the values
+// read so far.
+//
+// Every input is read by its own goroutine. They only stop once all inputs
+// have ended, so use MergeWithOpts and WithMergeContext if the merged source
+// might not be read to the end.
+func Merge(srcs ...Source) Source {
+	return MergeWithOpts(srcs)
+}
+
+// MergeWithOpts is like Merge, but accepts options.
+func MergeWithOpts(srcs []Source, opts ...MergeOpt) Source {
+	mOpts := mergeOpts{ctx: context.Background()}
+
+	for i, opt := range opts {
+		err := opt(&mOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid merge option %d", i))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(mOpts.ctx)
+	out, sink := NewPipe()
+
+	var (
+		wg sync.WaitGroup
+
+		// protects merr
+		l    sync.Mutex
+		merr *multierror.Error
+	)
+
+	wg.Add(len(srcs))
+	for _, src := range srcs {
+		go func(src Source) {
+			defer wg.Done()
+
+			for {
+				v, err := src.Next(ctx)
+				if IsEOS(err) {
+					return
+				} else if err != nil {
+					// errors caused by cancellation are not the input's fault
+					if ctx.Err() != nil {
+						return
+					}
+
+					l.Lock()
+					merr = multierror.Append(merr, err)
+					l.Unlock()
+
+					if !mOpts.collectErrors {
+						cancel()
+					}
+					return
+				}
+
+				if sink.Pour(ctx, v) != nil {
+					return
+				}
+			}
+		}(src)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+
+		var err error
+		if merr != nil && !mOpts.collectErrors {
+			err = merr.Errors[0]
+		} else if merr != nil {
+			err = merr
+		} else if mOpts.ctx.Err() != nil {
+			err = mOpts.ctx.Err()
+		}
+
+		if err != nil {
+			sink.(ErrorCloser).CloseWithError(err)
+		} else {
+			sink.Close()
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,104 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSource returns the given values and then fails with err.
+func failingSource(err error, vs ...interface{}) Source {
+	src := SliceSource(vs)
+	return FuncSource(func(ctx context.Context) (interface{}, error) {
+		v, srcErr := src.Next(ctx)
+		if IsEOS(srcErr) {
+			return nil, err
+		}
+		return v, srcErr
+	})
+}
+
+func sortInts(vs []interface{}) []interface{} {
+	sort.Slice(vs, func(i, j int) bool { return vs[i].(int) < vs[j].(int) })
+	return vs
+}
+
+func TestMerge(t *testing.T) {
+	r := require.New(t)
+
+	a := SliceSource([]interface{}{1, 3, 5})
+	b := SliceSource([]interface{}{2, 4})
+	c := SliceSource([]interface{}{})
+
+	out := drain(t, Merge(&a, &b, &c))
+	r.Equal([]interface{}{1, 2, 3, 4, 5}, sortInts(out))
+
+	_, err := Merge().Next(context.Background())
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestMergeError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	testErr := errors.New("test error")
+	live, _ := NewPipe()
+
+	src := Merge(failingSource(testErr, 1), live)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	_, err = src.Next(ctx)
+	r.Equal(testErr, err)
+}
+
+func TestMergeCollectErrors(t *testing.T) {
+	r := require.New(t)
+
+	errA, errB := errors.New("a"), errors.New("b")
+	ok := SliceSource([]interface{}{3, 4})
+
+	src := MergeWithOpts([]Source{failingSource(errA, 1), failingSource(errB, 2), &ok}, CollectErrors())
+
+	var (
+		out []interface{}
+		err error
+	)
+	for {
+		var v interface{}
+		v, err = src.Next(context.Background())
+		if err != nil {
+			break
+		}
+		out = append(out, v)
+	}
+
+	r.Equal([]interface{}{1, 2, 3, 4}, sortInts(out))
+
+	var merr *multierror.Error
+	r.True(errors.As(err, &merr), "expected multierror, got %v", err)
+	r.Len(merr.Errors, 2)
+}
+
+func TestMergeCancel(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	live, _ := NewPipe()
+
+	src := MergeWithOpts([]Source{live}, WithMergeContext(ctx))
+	cancel()
+
+	_, err := src.Next(context.Background())
+	r.Equal(context.Canceled, err)
+}
@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// batch collects the values an operator's sink produced from a batch, so
+// that they can be poured at once.
+type batch struct {
+	in  []interface{}
+	out []interface{}
+
+	// idx holds the index in in of each value in out
+	idx []int
+}
+
+func newBatch(vs []interface{}) *batch {
+	return &batch{
+		in:  vs,
+		out: make([]interface{}, 0, len(vs)),
+		idx: make([]int, 0, len(vs)),
+	}
+}
+
+// add adds v, which was produced from in[i].
+func (b *batch) add(i int, v interface{}) {
+	b.out = append(b.out, v)
+	b.idx = append(b.idx, i)
+}
+
+// pour pours the collected values into sink. If err is not nil, the operator
+// failed on in[failed], and the error for the batch failing there is
+// returned after pouring the values produced before.
+func (b *batch) pour(ctx context.Context, sink luigi.Sink, failed int, err error) error {
+	if len(b.out) > 0 {
+		pErr := luigi.PourBatch(ctx, sink, b.out)
+		if pErr != nil {
+			var n int
+			if bErr, ok := pErr.(*luigi.BatchError); ok {
+				n = len(b.out) - len(bErr.Rest)
+				pErr = bErr.Err
+			}
+			return batchError(b.in, b.idx[n], pErr)
+		}
+	}
+
+	if err != nil {
+		return batchError(b.in, failed, err)
+	}
+
+	return nil
+}
+
+// batchError returns the error for a batch that failed at vs[i].
+func batchError(vs []interface{}, i int, err error) error {
+	if i == 0 {
+		return err
+	}
+
+	return &luigi.BatchError{Rest: vs[i:], Err: err}
+}
+
+// readAhead holds the values an operator's source read from upstream but did
+// not process yet, because an earlier value in the batch failed, and the
+// error of that value. It is safe for concurrent use, but the lock is not
+// held while reading from upstream.
+type readAhead struct {
+	l       sync.Mutex
+	pending []interface{}
+	err     error
+}
+
+// take returns the pending error or up to max pending values, and false if
+// there are none.
+func (ra *readAhead) take(max int) ([]interface{}, bool, error) {
+	ra.l.Lock()
+	defer ra.l.Unlock()
+
+	if err := ra.err; err != nil {
+		ra.err = nil
+		return nil, true, err
+	}
+
+	if len(ra.pending) == 0 {
+		return nil, false, nil
+	}
+
+	if max < 1 {
+		max = 1
+	}
+	if max > len(ra.pending) {
+		max = len(ra.pending)
+	}
+
+	vs := ra.pending[:max:max]
+	ra.pending = ra.pending[max:]
+	return vs, true, nil
+}
+
+// next returns the pending error or up to max pending values, and reads from
+// src if there are none.
+func (ra *readAhead) next(ctx context.Context, src luigi.Source, max int) ([]interface{}, error) {
+	if vs, ok, err := ra.take(max); ok {
+		return vs, err
+	}
+
+	return luigi.NextBatch(ctx, src, max)
+}
+
+// nextOne is like next, but returns a single value and reads it using Next.
+func (ra *readAhead) nextOne(ctx context.Context, src luigi.Source) (interface{}, error) {
+	vs, ok, err := ra.take(1)
+	if !ok {
+		return src.Next(ctx)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return vs[0], nil
+}
+
+// fail handles err, returned for a value of a batch. The values processed
+// before it are returned first, and err is returned by the following call.
+// rest holds the values after the failed one.
+func (ra *readAhead) fail(out, rest []interface{}, err error) ([]interface{}, error) {
+	ra.l.Lock()
+	defer ra.l.Unlock()
+
+	if len(rest) > 0 {
+		ra.pending = append(rest[:len(rest):len(rest)], ra.pending...)
+	}
+
+	if len(out) == 0 {
+		return nil, err
+	}
+
+	ra.err = err
+	return out, nil
+}
@@ -0,0 +1,17 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"fmt"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// invalidf returns an error of kind luigi.ErrInvalid with the formatted
+// message.
+func invalidf(format string, args ...interface{}) error {
+	return &luigi.Error{Kind: luigi.ErrInvalid, Msg: fmt.Sprintf(format, args...)}
+}
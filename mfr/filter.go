@@ -14,23 +14,25 @@ import (
 type FilterFunc func(ctx context.Context, v interface{}) (bool, error)
 
 // SinkFilter returns a new Sink whose values are selected according to the
-// given FilterFunc.
-func SinkFilter(sink luigi.Sink, f FilterFunc) luigi.Sink {
+// given FilterFunc. The ErrorOpts set what happens when f fails.
+func SinkFilter(sink luigi.Sink, f FilterFunc, opts ...ErrorOpt) luigi.Sink {
 	return &sinkFilter{
 		Sink: sink,
 		f:    f,
+		opts: newErrorOpts(opts),
 	}
 }
 
 type sinkFilter struct {
 	luigi.Sink
 
-	f FilterFunc
+	f    FilterFunc
+	opts errorOpts
 }
 
 // Pour implements the luigi.Sink interface.
 func (sink *sinkFilter) Pour(ctx context.Context, v interface{}) error {
-	pass, err := sink.f(ctx, v)
+	pass, err := sink.opts.filterValue(ctx, sink.f, v)
 	if err == nil && pass {
 		err = sink.Sink.Pour(ctx, v)
 	}
@@ -38,19 +40,38 @@ func (sink *sinkFilter) Pour(ctx context.Context, v interface{}) error {
 	return err
 }
 
+// PourBatch implements the luigi.BatchSink interface.
+func (sink *sinkFilter) PourBatch(ctx context.Context, vs []interface{}) error {
+	b := newBatch(vs)
+	for i, v := range vs {
+		pass, err := sink.opts.filterValue(ctx, sink.f, v)
+		if err != nil {
+			return b.pour(ctx, sink.Sink, i, err)
+		}
+		if pass {
+			b.add(i, v)
+		}
+	}
+
+	return b.pour(ctx, sink.Sink, len(vs), nil)
+}
+
 // SinkFilter returns a new Source whose values are filtered according to the
-// given FilterFunc.
-func SourceFilter(src luigi.Source, f FilterFunc) luigi.Source {
+// given FilterFunc. The ErrorOpts set what happens when f fails.
+func SourceFilter(src luigi.Source, f FilterFunc, opts ...ErrorOpt) luigi.Source {
 	return &srcFilter{
 		Source: src,
 		f:      f,
+		opts:   newErrorOpts(opts),
 	}
 }
 
 type srcFilter struct {
 	luigi.Source
 
-	f FilterFunc
+	f     FilterFunc
+	opts  errorOpts
+	ahead readAhead
 }
 
 // Pour implements the luigi.Source interface.
@@ -58,12 +79,12 @@ func (src *srcFilter) Next(ctx context.Context) (v interface{}, err error) {
 	var pass bool
 
 	for !pass {
-		v, err = src.Source.Next(ctx)
+		v, err = src.ahead.nextOne(ctx, src.Source)
 		if err != nil {
 			return nil, err
 		}
 
-		pass, err = src.f(ctx, v)
+		pass, err = src.opts.filterValue(ctx, src.f, v)
 		if err != nil {
 			return nil, err
 		}
@@ -71,3 +92,30 @@ func (src *srcFilter) Next(ctx context.Context) (v interface{}, err error) {
 
 	return v, nil
 }
+
+// NextBatch implements the luigi.BatchSource interface. It reads batches
+// until at least one value passes the filter. If f fails, the values before
+// are returned first, and the error by the next call.
+func (src *srcFilter) NextBatch(ctx context.Context, max int) ([]interface{}, error) {
+	for {
+		vs, err := src.ahead.next(ctx, src.Source, max)
+		if err != nil {
+			return nil, err
+		}
+
+		out := make([]interface{}, 0, len(vs))
+		for i, v := range vs {
+			pass, err := src.opts.filterValue(ctx, src.f, v)
+			if err != nil {
+				return src.ahead.fail(out, vs[i+1:], err)
+			}
+			if pass {
+				out = append(out, v)
+			}
+		}
+
+		if len(out) > 0 {
+			return out, nil
+		}
+	}
+}
@@ -6,6 +6,7 @@ package mfr // import "github.com/ssbc/go-luigi/mfr"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -280,3 +281,53 @@ func TestFilterSourcePiped(t *testing.T) {
 	}
 }
 */
+
+func TestFilterBatch(t *testing.T) {
+	ctx := context.Background()
+	isEven := func(_ context.Context, v interface{}) (bool, error) {
+		return v.(int)%2 == 0, nil
+	}
+
+	numbers := luigi.SliceSource([]interface{}{1, 3, 5, 6, 7, 8})
+	vs, err := luigi.NextBatch(ctx, SourceFilter(&numbers, isEven), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(vs) != "[6]" {
+		t.Errorf("expected [6], got %v", vs)
+	}
+
+	var out []interface{}
+	err = luigi.PourBatch(ctx, SinkFilter(luigi.NewSliceSink(&out), isEven), []interface{}{1, 2, 3, 4})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(out) != "[2 4]" {
+		t.Errorf("expected [2 4], got %v", out)
+	}
+}
+
+func TestFilterBatchError(t *testing.T) {
+	ctx := context.Background()
+	failErr := errors.New("four")
+	isOdd := func(_ context.Context, v interface{}) (bool, error) {
+		if v.(int) == 4 {
+			return false, failErr
+		}
+		return v.(int)%2 == 1, nil
+	}
+
+	numbers := luigi.SliceSource([]interface{}{1, 2, 3, 4, 5, 6})
+	filtered := SourceFilter(&numbers, isOdd)
+
+	vs, err := luigi.NextBatch(ctx, filtered, 10)
+	if err != nil || fmt.Sprint(vs) != "[1 3]" {
+		t.Fatalf("expected [1 3], got %v, %v", vs, err)
+	}
+	if _, err := filtered.Next(ctx); err != failErr {
+		t.Fatalf("expected filter error, got %v", err)
+	}
+	if v, err := filtered.Next(ctx); err != nil || v != 5 {
+		t.Fatalf("expected 5, got %v, %v", v, err)
+	}
+}
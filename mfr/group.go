@@ -0,0 +1,280 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/ssbc/go-luigi"
+)
+
+// KeyFunc returns the key of the group a value belongs to. Keys are compared
+// using ==, so they must be comparable. Next returns an error of kind
+// luigi.ErrInvalid for keys that are not.
+type KeyFunc func(ctx context.Context, v interface{}) (interface{}, error)
+
+// Group is a sub-stream returned by the Source of GroupBy. It returns all
+// values of the parent stream with the given key.
+type Group struct {
+	Key interface{}
+
+	luigi.Source
+}
+
+// GroupSource is the Source returned by GroupBy.
+type GroupSource interface {
+	luigi.Source
+
+	// Close stops routing values and closes all open groups.
+	Close() error
+}
+
+type groupOpts struct {
+	bufferSize int
+	idle       time.Duration
+	clock      luigi.Clock
+}
+
+// GroupOpt configures GroupBy's behavior
+type GroupOpt func(*groupOpts) error
+
+// WithGroupBuffer sets the number of values buffered for each group. It must
+// be at least one, which is the default.
+func WithGroupBuffer(bufSize int) GroupOpt {
+	return GroupOpt(func(opts *groupOpts) error {
+		if bufSize < 1 {
+			return invalidf("buffer size %d too small", bufSize)
+		}
+
+		opts.bufferSize = bufSize
+		return nil
+	})
+}
+
+// WithIdleExpiry closes groups that did not receive a value for the given
+// duration. A later value with the same key starts a new group.
+func WithIdleExpiry(d time.Duration) GroupOpt {
+	return GroupOpt(func(opts *groupOpts) error {
+		if d <= 0 {
+			return invalidf("idle duration %v not positive", d)
+		}
+
+		opts.idle = d
+		return nil
+	})
+}
+
+// WithGroupClock sets the Clock used for idle expiry. The default is
+// luigi.RealClock.
+func WithGroupClock(clk luigi.Clock) GroupOpt {
+	return GroupOpt(func(opts *groupOpts) error {
+		if clk == nil {
+			return invalidf("nil clock")
+		}
+
+		opts.clock = clk
+		return nil
+	})
+}
+
+// GroupBy splits src into sub-streams by the key returned by f. Next returns
+// a Group for every new key; the values themselves are read from the groups.
+//
+// Values are only routed while Next is being called, and Next blocks while the
+// buffer of the value's group is full, so every Group has to be read or the
+// whole stream stalls. When src ends or fails, all groups end the same way.
+func GroupBy(src luigi.Source, f KeyFunc, opts ...GroupOpt) GroupSource {
+	gOpts := groupOpts{bufferSize: 1, clock: luigi.RealClock}
+	for i, opt := range opts {
+		err := opt(&gOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid group option %d", i))
+		}
+	}
+
+	return &groupBy{
+		src:    src,
+		f:      f,
+		opts:   gOpts,
+		groups: make(map[interface{}]*group),
+	}
+}
+
+type groupBy struct {
+	src  luigi.Source
+	f    KeyFunc
+	opts groupOpts
+
+	l      sync.Mutex
+	groups map[interface{}]*group
+	closed bool
+}
+
+type group struct {
+	key  interface{}
+	sink luigi.Sink
+
+	pouring bool
+	last    time.Time
+
+	// stop is closed to stop the idle timer
+	stop chan struct{}
+}
+
+// Next implements the luigi.Source interface. It returns values of type Group.
+func (gb *groupBy) Next(ctx context.Context) (interface{}, error) {
+	for {
+		if gb.isClosed() {
+			return nil, luigi.EOS{}
+		}
+
+		v, err := gb.src.Next(ctx)
+		if err != nil {
+			// a cancelled context only affects this call
+			if ctx.Err() == nil || luigi.IsEOS(err) {
+				gb.closeGroups(err)
+			}
+			return nil, err
+		}
+
+		k, err := gb.f(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, invalidf("luigi: group key of type %T is not comparable", k)
+		}
+
+		gb.l.Lock()
+		if gb.closed {
+			gb.l.Unlock()
+			return nil, luigi.EOS{}
+		}
+
+		grp, ok := gb.groups[k]
+		if !ok {
+			// the buffer is empty, so pouring the first value can't block
+			src, sink := luigi.NewPipe(luigi.WithBuffer(gb.opts.bufferSize))
+			err := sink.Pour(ctx, v)
+			if err != nil {
+				gb.l.Unlock()
+				return nil, err
+			}
+
+			grp = &group{key: k, sink: sink}
+			gb.groups[k] = grp
+			gb.touch(grp)
+			gb.l.Unlock()
+
+			return Group{Key: k, Source: src}, nil
+		}
+
+		grp.pouring = true
+		gb.l.Unlock()
+
+		err = grp.sink.Pour(ctx, v)
+
+		gb.l.Lock()
+		grp.pouring = false
+		gb.touch(grp)
+		gb.l.Unlock()
+
+		if err != nil {
+			if gb.isClosed() {
+				return nil, luigi.EOS{}
+			}
+			return nil, err
+		}
+	}
+}
+
+// touch records that grp received a value and starts its idle timer if it
+// has none. It must be called with the lock held.
+func (gb *groupBy) touch(grp *group) {
+	if gb.opts.idle == 0 {
+		return
+	}
+
+	grp.last = gb.opts.clock.Now()
+	if grp.stop == nil {
+		grp.stop = make(chan struct{})
+		gb.startTimer(grp, gb.opts.idle)
+	}
+}
+
+// startTimer calls expire after d, unless grp is closed before.
+func (gb *groupBy) startTimer(grp *group, d time.Duration) {
+	t := gb.opts.clock.NewTimer(d)
+	go func() {
+		select {
+		case <-t.C():
+			gb.expire(grp)
+		case <-grp.stop:
+			t.Stop()
+		}
+	}()
+}
+
+// expire closes grp if it has been idle long enough, and otherwise restarts
+// the timer for the remaining time.
+func (gb *groupBy) expire(grp *group) {
+	gb.l.Lock()
+	defer gb.l.Unlock()
+
+	if gb.groups[grp.key] != grp {
+		return
+	}
+
+	idle := gb.opts.clock.Now().Sub(grp.last)
+	if grp.pouring {
+		idle = 0
+	}
+	if idle < gb.opts.idle {
+		gb.startTimer(grp, gb.opts.idle-idle)
+		return
+	}
+
+	delete(gb.groups, grp.key)
+	close(grp.stop)
+	grp.sink.Close()
+}
+
+func (gb *groupBy) isClosed() bool {
+	gb.l.Lock()
+	defer gb.l.Unlock()
+
+	return gb.closed
+}
+
+// closeGroups closes all groups with err, or normally if err is nil or EOS.
+func (gb *groupBy) closeGroups(err error) {
+	gb.l.Lock()
+	defer gb.l.Unlock()
+
+	gb.closed = true
+	for k, grp := range gb.groups {
+		if grp.stop != nil {
+			close(grp.stop)
+		}
+
+		if err == nil || luigi.IsEOS(err) {
+			grp.sink.Close()
+		} else {
+			grp.sink.(luigi.ErrorCloser).CloseWithError(err)
+		}
+
+		delete(gb.groups, k)
+	}
+}
+
+// Close implements the GroupSource interface.
+func (gb *groupBy) Close() error {
+	gb.closeGroups(nil)
+	return nil
+}
@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+// The tests in this file use ltime, which imports mfr.
+package mfr_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-luigi/ltime"
+	"github.com/ssbc/go-luigi/mfr"
+	"github.com/stretchr/testify/require"
+)
+
+func parity(_ context.Context, v interface{}) (interface{}, error) {
+	return v.(int) % 2, nil
+}
+
+func TestGroupByIdleExpiry(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := ltime.NewFakeClock(time.Unix(0, 0))
+	in, inSink := luigi.NewPipe(luigi.WithBuffer(2))
+	groups := mfr.GroupBy(in, parity, mfr.WithIdleExpiry(10*time.Second), mfr.WithGroupClock(clk))
+
+	r.NoError(inSink.Pour(ctx, 1))
+	v, err := groups.Next(ctx)
+	r.NoError(err)
+	first := v.(mfr.Group)
+
+	v, err = first.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	clk.BlockUntil(1)
+	clk.Advance(9 * time.Second)
+	r.Equal(1, clk.Timers(), "the group should not expire early")
+	clk.Advance(time.Second)
+
+	_, err = first.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+
+	r.NoError(inSink.Pour(ctx, 3))
+	v, err = groups.Next(ctx)
+	r.NoError(err)
+	second := v.(mfr.Group)
+	r.Equal(1, second.Key)
+
+	v, err = second.Next(ctx)
+	r.NoError(err)
+	r.Equal(3, v, "an expired key should start a new group")
+
+	r.NoError(groups.Close())
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func parity(_ context.Context, v interface{}) (interface{}, error) {
+	return v.(int) % 2, nil
+}
+
+func TestGroupBy(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in := luigi.SliceSource([]interface{}{1, 2, 3, 4, 5})
+	groups := GroupBy(&in, parity, WithGroupBuffer(4))
+
+	var got []Group
+	for {
+		v, err := groups.Next(ctx)
+		if luigi.IsEOS(err) {
+			break
+		}
+		r.NoError(err)
+		got = append(got, v.(Group))
+	}
+
+	r.Len(got, 2)
+	r.Equal(1, got[0].Key)
+	r.Equal(0, got[1].Key)
+
+	var odd, even []interface{}
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&odd), got[0]))
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&even), got[1]))
+	r.Equal([]interface{}{1, 3, 5}, odd)
+	r.Equal([]interface{}{2, 4}, even)
+}
+
+func TestGroupByClose(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in, inSink := luigi.NewPipe(luigi.WithBuffer(1))
+	groups := GroupBy(in, parity)
+
+	r.NoError(inSink.Pour(ctx, 1))
+	v, err := groups.Next(ctx)
+	r.NoError(err)
+	grp := v.(Group)
+
+	r.NoError(groups.Close())
+
+	v, err = grp.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v, "buffered values are still returned")
+	_, err = grp.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+
+	_, err = groups.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestGroupByError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in, inSink := luigi.NewPipe(luigi.WithBuffer(1))
+	groups := GroupBy(in, parity)
+
+	r.NoError(inSink.Pour(ctx, 1))
+	v, err := groups.Next(ctx)
+	r.NoError(err)
+	grp := v.(Group)
+	_, err = grp.Next(ctx)
+	r.NoError(err)
+
+	testErr := errors.New("test error")
+	r.NoError(inSink.(luigi.ErrorCloser).CloseWithError(testErr))
+
+	_, err = groups.Next(ctx)
+	r.Equal(testErr, err)
+	_, err = grp.Next(ctx)
+	r.Equal(testErr, err, "groups should fail with the source's error")
+}
+
+func TestGroupByUncomparableKey(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src := luigi.SliceSource([]interface{}{1, 2})
+	groups := GroupBy(&src, func(_ context.Context, v interface{}) (interface{}, error) {
+		if v == 1 {
+			return []int{1}, nil
+		}
+		return v, nil
+	})
+
+	var err error
+	r.NotPanics(func() { _, err = groups.Next(ctx) })
+	r.True(errors.Is(err, luigi.ErrInvalid), "got %v", err)
+
+	v, err := groups.Next(ctx)
+	r.NoError(err)
+	r.Equal(2, v.(Group).Key, "later values should still be grouped")
+}
@@ -14,51 +14,107 @@ import (
 type MapFunc func(context.Context, interface{}) (interface{}, error)
 
 // SinkMap returns a Sink which writes converted values to its argument
-// according to a given MapFunc.
-func SinkMap(sink luigi.Sink, f MapFunc) luigi.Sink {
+// according to a given MapFunc. The ErrorOpts set what happens when f fails.
+func SinkMap(sink luigi.Sink, f MapFunc, opts ...ErrorOpt) luigi.Sink {
 	return &sinkMap{
 		Sink: sink,
 		f:    f,
+		opts: newErrorOpts(opts),
 	}
 }
 
 type sinkMap struct {
 	luigi.Sink
 
-	f MapFunc
+	f    MapFunc
+	opts errorOpts
 }
 
 // Next implements the luigi.Sink interface.
 func (sink *sinkMap) Pour(ctx context.Context, v interface{}) error {
-	v, err := sink.f(ctx, v)
-	if err != nil {
+	v, skip, err := sink.opts.mapValue(ctx, sink.f, v)
+	if err != nil || skip {
 		return err
 	}
 
 	return sink.Sink.Pour(ctx, v)
 }
 
+// PourBatch implements the luigi.BatchSink interface.
+func (sink *sinkMap) PourBatch(ctx context.Context, vs []interface{}) error {
+	b := newBatch(vs)
+	for i, v := range vs {
+		v, skip, err := sink.opts.mapValue(ctx, sink.f, v)
+		if err != nil {
+			return b.pour(ctx, sink.Sink, i, err)
+		}
+		if !skip {
+			b.add(i, v)
+		}
+	}
+
+	return b.pour(ctx, sink.Sink, len(vs), nil)
+}
+
 // SinkMap returns a new Source which produces converted values according to a
-// given MapFunc.
-func SourceMap(src luigi.Source, f MapFunc) luigi.Source {
+// given MapFunc. The ErrorOpts set what happens when f fails.
+func SourceMap(src luigi.Source, f MapFunc, opts ...ErrorOpt) luigi.Source {
 	return &srcMap{
 		Source: src,
 		f:      f,
+		opts:   newErrorOpts(opts),
 	}
 }
 
 type srcMap struct {
 	luigi.Source
 
-	f MapFunc
+	f     MapFunc
+	opts  errorOpts
+	ahead readAhead
 }
 
 // Next implements the luigi.Source interface.
 func (src *srcMap) Next(ctx context.Context) (interface{}, error) {
-	v, err := src.Source.Next(ctx)
-	if err != nil {
-		return nil, err
+	for {
+		v, err := src.ahead.nextOne(ctx, src.Source)
+		if err != nil {
+			return nil, err
+		}
+
+		v, skip, err := src.opts.mapValue(ctx, src.f, v)
+		if err != nil {
+			return nil, err
+		}
+		if !skip {
+			return v, nil
+		}
 	}
+}
+
+// NextBatch implements the luigi.BatchSource interface. It reads batches
+// until at least one value is not skipped. If f fails, the values before are
+// returned first, and the error by the next call.
+func (src *srcMap) NextBatch(ctx context.Context, max int) ([]interface{}, error) {
+	for {
+		vs, err := src.ahead.next(ctx, src.Source, max)
+		if err != nil {
+			return nil, err
+		}
 
-	return src.f(ctx, v)
+		out := make([]interface{}, 0, len(vs))
+		for i, v := range vs {
+			v, skip, err := src.opts.mapValue(ctx, src.f, v)
+			if err != nil {
+				return src.ahead.fail(out, vs[i+1:], err)
+			}
+			if !skip {
+				out = append(out, v)
+			}
+		}
+
+		if len(out) > 0 {
+			return out, nil
+		}
+	}
 }
@@ -6,7 +6,9 @@ package mfr // import "github.com/ssbc/go-luigi/mfr"
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/ssbc/go-luigi"
@@ -189,3 +191,137 @@ func TestMapSource(t *testing.T) {
 		t.Run(fmt.Sprint(i), mkTest(tc))
 	}
 }
+
+func TestMapBatch(t *testing.T) {
+	ctx := context.Background()
+	double := func(_ context.Context, v interface{}) (interface{}, error) {
+		return v.(int) * 2, nil
+	}
+
+	numbers := luigi.SliceSource([]interface{}{1, 2, 3, 4})
+	vs, err := luigi.NextBatch(ctx, SourceMap(&numbers, double), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(vs) != "[2 4 6]" {
+		t.Errorf("expected [2 4 6], got %v", vs)
+	}
+
+	var out []interface{}
+	err = luigi.PourBatch(ctx, SinkMap(luigi.NewSliceSink(&out), double), []interface{}{5, 6})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(out) != "[10 12]" {
+		t.Errorf("expected [10 12], got %v", out)
+	}
+}
+
+func TestMapBatchError(t *testing.T) {
+	ctx := context.Background()
+	failErr := errors.New("four")
+	f := func(_ context.Context, v interface{}) (interface{}, error) {
+		if v.(int) == 4 {
+			return nil, failErr
+		}
+		return v, nil
+	}
+
+	numbers := luigi.SliceSource([]interface{}{1, 2, 3, 4, 5, 6})
+	mapped := SourceMap(&numbers, f)
+
+	var out []interface{}
+	err := luigi.PumpBatch(ctx, luigi.NewSliceSink(&out), mapped)
+	if err != failErr {
+		t.Fatalf("expected map error, got %v", err)
+	}
+	if fmt.Sprint(out) != "[1 2 3]" {
+		t.Errorf("expected the values before the error, got %v", out)
+	}
+
+	out = nil
+	if err := luigi.PumpBatch(ctx, luigi.NewSliceSink(&out), mapped); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(out) != "[5 6]" {
+		t.Errorf("expected the values after the error, got %v", out)
+	}
+
+	out = nil
+	err = luigi.PourBatch(ctx, SinkMap(luigi.NewSliceSink(&out), f), []interface{}{1, 2, 3, 4, 5, 6})
+	var bErr *luigi.BatchError
+	if !errors.As(err, &bErr) || bErr.Err != failErr {
+		t.Fatalf("expected BatchError, got %v", err)
+	}
+	if fmt.Sprint(out) != "[1 2 3]" || fmt.Sprint(bErr.Rest) != "[4 5 6]" {
+		t.Errorf("expected [1 2 3] poured and [4 5 6] left, got %v and %v", out, bErr.Rest)
+	}
+}
+
+func TestMapBatchConcurrent(t *testing.T) {
+	ctx := context.Background()
+	failErr := errors.New("multiple of ten")
+	f := func(_ context.Context, v interface{}) (interface{}, error) {
+		if v.(int)%10 == 0 {
+			return nil, failErr
+		}
+		return v, nil
+	}
+
+	const n = 1000
+	src, sink := luigi.NewPipe(luigi.WithBuffer(n))
+	for i := 0; i < n; i++ {
+		if err := sink.Pour(ctx, i); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	sink.Close()
+	mapped := SourceMap(src, f)
+
+	var (
+		wg   sync.WaitGroup
+		l    sync.Mutex
+		seen = make(map[int]int)
+		errs int
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(batched bool) {
+			defer wg.Done()
+			for {
+				var vs []interface{}
+				var err error
+				if batched {
+					vs, err = luigi.NextBatch(ctx, mapped, 7)
+				} else {
+					var v interface{}
+					v, err = mapped.Next(ctx)
+					vs = []interface{}{v}
+				}
+
+				l.Lock()
+				if luigi.IsEOS(err) {
+					l.Unlock()
+					return
+				} else if err != nil {
+					errs++
+				} else {
+					for _, v := range vs {
+						seen[v.(int)]++
+					}
+				}
+				l.Unlock()
+			}
+		}(w%2 == 0)
+	}
+	wg.Wait()
+
+	if errs != n/10 {
+		t.Errorf("expected %d errors, got %d", n/10, errs)
+	}
+	for i := 0; i < n; i++ {
+		if want := map[bool]int{true: 0, false: 1}[i%10 == 0]; seen[i] != want {
+			t.Errorf("expected %d to be returned %d times, got %d", i, want, seen[i])
+		}
+	}
+}
@@ -0,0 +1,377 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/ssbc/go-luigi"
+)
+
+type parallelOpts struct {
+	ctx       context.Context
+	workers   int
+	unordered bool
+}
+
+// ParallelOpt configures the behavior of SourceParallelMap and SinkParallelMap
+type ParallelOpt func(*parallelOpts) error
+
+// WithWorkers sets the number of values mapped concurrently. It defaults to
+// GOMAXPROCS.
+func WithWorkers(n int) ParallelOpt {
+	return ParallelOpt(func(opts *parallelOpts) error {
+		if n < 1 {
+			return invalidf("worker count %d too small", n)
+		}
+
+		opts.workers = n
+		return nil
+	})
+}
+
+// Unordered emits values as soon as they are mapped instead of in the order
+// they were read.
+func Unordered() ParallelOpt {
+	return ParallelOpt(func(opts *parallelOpts) error {
+		opts.unordered = true
+		return nil
+	})
+}
+
+// WithParallelContext sets the context passed to the MapFunc. Once it is
+// cancelled, no more values are mapped and the stream fails with its error.
+func WithParallelContext(ctx context.Context) ParallelOpt {
+	return ParallelOpt(func(opts *parallelOpts) error {
+		if ctx == nil {
+			return invalidf("nil context")
+		}
+
+		opts.ctx = ctx
+		return nil
+	})
+}
+
+func newParallelOpts(opts []ParallelOpt) parallelOpts {
+	pOpts := parallelOpts{
+		ctx:     context.Background(),
+		workers: runtime.GOMAXPROCS(0),
+	}
+
+	for i, opt := range opts {
+		err := opt(&pOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid parallel option %d", i))
+		}
+	}
+
+	return pOpts
+}
+
+// SourceParallelMap is like SourceMap, but maps several values concurrently.
+// Unless Unordered is passed, values are still returned in the order they
+// were read.
+//
+// If f fails, the work in flight is cancelled and the returned Source fails
+// with that error once the values before it have been read. src is read by a
+// goroutine that stops when src ends or fails, so use WithParallelContext if
+// the returned Source might not be read to the end.
+func SourceParallelMap(src luigi.Source, f MapFunc, opts ...ParallelOpt) luigi.Source {
+	pOpts := newParallelOpts(opts)
+	out, sink := luigi.NewPipe()
+	p := newParallel(f, sink.Pour, pOpts)
+
+	go func() {
+		var srcErr error
+		for {
+			v, err := src.Next(p.ctx)
+			if err != nil {
+				srcErr = err
+				break
+			}
+
+			if p.submit(p.ctx, v) != nil {
+				break
+			}
+		}
+
+		err := p.finish()
+		if err == nil && pOpts.ctx.Err() != nil {
+			err = pOpts.ctx.Err()
+		} else if err == nil && srcErr != nil && !luigi.IsEOS(srcErr) {
+			err = srcErr
+		}
+
+		closeSink(sink, err)
+	}()
+
+	return out
+}
+
+// SinkParallelMap is like SinkMap, but maps several values concurrently.
+// Pour returns as soon as a worker picked up the value, and the mapped values
+// are poured into sink by a separate goroutine. Unless Unordered is passed,
+// they are poured in the order they were received.
+//
+// If f or sink fail, the work in flight is cancelled and later calls to Pour
+// return that error. Close waits for the remaining values and closes sink,
+// using CloseWithError if a value failed.
+func SinkParallelMap(sink luigi.Sink, f MapFunc, opts ...ParallelOpt) luigi.Sink {
+	pOpts := newParallelOpts(opts)
+
+	return &sinkParallelMap{
+		sink: sink,
+		p:    newParallel(f, sink.Pour, pOpts),
+	}
+}
+
+type sinkParallelMap struct {
+	sink luigi.Sink
+	p    *parallel
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink *sinkParallelMap) Pour(ctx context.Context, v interface{}) error {
+	return sink.p.submit(ctx, v)
+}
+
+// Close implements the luigi.Sink interface.
+func (sink *sinkParallelMap) Close() error {
+	err := sink.p.finish()
+	cErr := closeSink(sink.sink, err)
+	if err != nil {
+		return err
+	}
+
+	return cErr
+}
+
+// CloseWithError implements the luigi.ErrorCloser interface. It cancels the
+// work in flight and closes the underlying sink with err.
+func (sink *sinkParallelMap) CloseWithError(err error) error {
+	sink.p.fail(err)
+	sink.p.finish()
+
+	return closeSink(sink.sink, err)
+}
+
+// closeSink closes sink with err, if it is not nil and sink supports it.
+func closeSink(sink luigi.Sink, err error) error {
+	if ec, ok := sink.(luigi.ErrorCloser); ok && err != nil {
+		return ec.CloseWithError(err)
+	}
+
+	return sink.Close()
+}
+
+type parallelJob struct {
+	v   interface{}
+	res chan parallelResult
+}
+
+type parallelResult struct {
+	v    interface{}
+	err  error
+	skip bool
+}
+
+// parallel hands values to a set of workers and passes their results to
+// emit, either in order or as they complete.
+type parallel struct {
+	f    MapFunc
+	emit func(context.Context, interface{}) error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	jobs chan parallelJob
+
+	// pending holds the result channel of every job in order. It is nil if
+	// the results are unordered.
+	pending chan chan parallelResult
+
+	// results is shared by all jobs if they are unordered.
+	results chan parallelResult
+
+	workers sync.WaitGroup
+	done    chan struct{}
+
+	// sendL is held for reading while submit sends on jobs and pending, and
+	// for writing when finish closes them
+	sendL  sync.RWMutex
+	closed bool
+
+	l   sync.Mutex
+	err error
+}
+
+func newParallel(f MapFunc, emit func(context.Context, interface{}) error, opts parallelOpts) *parallel {
+	ctx, cancel := context.WithCancel(opts.ctx)
+
+	p := &parallel{
+		f:      f,
+		emit:   emit,
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(chan parallelJob),
+		done:   make(chan struct{}),
+	}
+
+	p.workers.Add(opts.workers)
+	for i := 0; i < opts.workers; i++ {
+		go p.work()
+	}
+
+	if opts.unordered {
+		p.results = make(chan parallelResult, opts.workers)
+		go func() {
+			p.workers.Wait()
+			close(p.results)
+		}()
+		go p.emitUnordered()
+	} else {
+		p.pending = make(chan chan parallelResult, opts.workers)
+		go p.emitOrdered()
+	}
+
+	return p
+}
+
+func (p *parallel) work() {
+	defer p.workers.Done()
+
+	for j := range p.jobs {
+		v, err := p.f(p.ctx, j.v)
+		j.res <- parallelResult{v: v, err: err}
+	}
+}
+
+// submit hands v to a worker. It blocks while all workers are busy. After
+// finish it returns ErrPourToClosedSink.
+func (p *parallel) submit(ctx context.Context, v interface{}) error {
+	p.sendL.RLock()
+	defer p.sendL.RUnlock()
+
+	if p.closed {
+		return luigi.ErrPourToClosedSink
+	}
+
+	if err := p.firstErr(); err != nil {
+		return err
+	}
+
+	j := parallelJob{v: v, res: p.results}
+	if p.pending != nil {
+		j.res = make(chan parallelResult, 1)
+
+		select {
+		case p.pending <- j.res:
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "luigi pour done")
+		case <-p.ctx.Done():
+			return p.ctxErr()
+		}
+	}
+
+	select {
+	case p.jobs <- j:
+		return nil
+	case <-ctx.Done():
+		err := errors.Wrap(ctx.Err(), "luigi pour done")
+		if p.pending != nil {
+			j.res <- parallelResult{skip: true}
+		}
+		return err
+	case <-p.ctx.Done():
+		if p.pending != nil {
+			j.res <- parallelResult{skip: true}
+		}
+		return p.ctxErr()
+	}
+}
+
+func (p *parallel) emitOrdered() {
+	defer close(p.done)
+
+	for res := range p.pending {
+		p.deliver(<-res)
+	}
+}
+
+func (p *parallel) emitUnordered() {
+	defer close(p.done)
+
+	for r := range p.results {
+		p.deliver(r)
+	}
+}
+
+// deliver emits the result unless an earlier one failed.
+func (p *parallel) deliver(r parallelResult) {
+	if r.skip || p.firstErr() != nil {
+		return
+	}
+
+	err := r.err
+	if err == nil {
+		err = p.emit(p.ctx, r.v)
+	}
+
+	if err != nil {
+		p.fail(err)
+	}
+}
+
+// finish waits until all submitted values are handled and returns the first
+// error.
+func (p *parallel) finish() error {
+	p.sendL.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+		if p.pending != nil {
+			close(p.pending)
+		}
+	}
+	p.sendL.Unlock()
+
+	<-p.done
+	p.cancel()
+
+	return p.firstErr()
+}
+
+// fail records err, unless there already is an error, and cancels the work
+// in flight.
+func (p *parallel) fail(err error) {
+	p.l.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.l.Unlock()
+
+	p.cancel()
+}
+
+// firstErr returns the first error.
+func (p *parallel) firstErr() error {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	return p.err
+}
+
+// ctxErr returns the first error, or the error of the workers' context if
+// there is none.
+func (p *parallel) ctxErr() error {
+	if err := p.firstErr(); err != nil {
+		return err
+	}
+
+	return p.ctx.Err()
+}
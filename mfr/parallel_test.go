@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+// slowSquare takes longer for smaller values, so workers finish out of order.
+func slowSquare(ctx context.Context, v interface{}) (interface{}, error) {
+	i := v.(int)
+	select {
+	case <-time.After(time.Duration(10-i) * time.Millisecond):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return i * i, nil
+}
+
+func TestParallelMap(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in := []interface{}{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	squares := []interface{}{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}
+
+	src := luigi.SliceSource(in)
+	var out []interface{}
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceParallelMap(&src, slowSquare, WithWorkers(4))))
+	r.Equal(squares, out)
+
+	src = luigi.SliceSource(in)
+	out = nil
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceParallelMap(&src, slowSquare, WithWorkers(4), Unordered())))
+	sort.Slice(out, func(i, j int) bool { return out[i].(int) < out[j].(int) })
+	r.Equal(squares, out)
+
+	out = nil
+	sink := SinkParallelMap(luigi.NewSliceSink(&out), slowSquare, WithWorkers(4))
+	for _, v := range in {
+		r.NoError(sink.Pour(ctx, v))
+	}
+	r.NoError(sink.Close())
+	r.Equal(squares, out)
+}
+
+func TestParallelMapError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	testErr := errors.New("test error")
+	var cancelled int32
+	started := make(chan struct{})
+	f := func(ctx context.Context, v interface{}) (interface{}, error) {
+		switch i := v.(int); {
+		case i == 3:
+			<-started
+			return nil, testErr
+		case i == 4:
+			close(started)
+			fallthrough
+		case i > 4:
+			<-ctx.Done()
+			atomic.AddInt32(&cancelled, 1)
+			return nil, ctx.Err()
+		}
+		return v, nil
+	}
+
+	src := luigi.SliceSource([]interface{}{0, 1, 2, 3, 4, 5, 6, 7})
+	var out []interface{}
+	err := luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceParallelMap(&src, f, WithWorkers(3)))
+	r.Equal(testErr, err)
+	r.Equal([]interface{}{0, 1, 2}, out, "values before the error should be emitted")
+	r.NotZero(atomic.LoadInt32(&cancelled), "work in flight should be cancelled")
+}
+
+func TestParallelMapCloseWithError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	pipeSrc, pipeSink := luigi.NewPipe(luigi.WithBuffer(4))
+	sink := SinkParallelMap(pipeSink, slowSquare, WithWorkers(2))
+	r.NoError(sink.Pour(ctx, 1))
+
+	testErr := errors.New("test error")
+	r.NoError(sink.(luigi.ErrorCloser).CloseWithError(testErr))
+
+	for {
+		_, err := pipeSrc.Next(ctx)
+		if err != nil {
+			r.Equal(testErr, err, "error should be passed downstream")
+			break
+		}
+	}
+}
+
+func TestParallelMapPourAfterClose(t *testing.T) {
+	ctx := context.Background()
+
+	for name, opts := range map[string][]ParallelOpt{
+		"ordered":   {WithWorkers(2)},
+		"unordered": {WithWorkers(2), Unordered()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			var out []interface{}
+			sink := SinkParallelMap(luigi.NewSliceSink(&out), slowSquare, opts...)
+			r.NoError(sink.Pour(ctx, 9))
+			r.NoError(sink.Close())
+			r.Equal(luigi.ErrPourToClosedSink, sink.Pour(ctx, 9))
+
+			sink = SinkParallelMap(luigi.NewSliceSink(&out), slowSquare, opts...)
+			r.NoError(sink.(luigi.ErrorCloser).CloseWithError(errors.New("test error")))
+			r.Equal(luigi.ErrPourToClosedSink, sink.Pour(ctx, 9))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/ssbc/go-luigi"
+)
+
+// DeadLetter is poured into the sink set using OnErrorDeadLetter for every
+// value an operator failed to process.
+type DeadLetter struct {
+	// Value is the value that was passed to the operator.
+	Value interface{}
+
+	// Err is the error returned by the MapFunc or FilterFunc.
+	Err error
+
+	// Stage is the name set using WithStage.
+	Stage string
+}
+
+func (dl DeadLetter) String() string {
+	if dl.Stage == "" {
+		return fmt.Sprintf("dead letter %v: %s", dl.Value, dl.Err)
+	}
+
+	return fmt.Sprintf("dead letter %v in stage %s: %s", dl.Value, dl.Stage, dl.Err)
+}
+
+type errorAction int
+
+const (
+	errorAbort errorAction = iota
+	errorSkip
+	errorDeadLetter
+)
+
+type errorOpts struct {
+	action errorAction
+	retry  luigi.RetryPolicy
+	dead   luigi.Sink
+	stage  string
+}
+
+// ErrorOpt sets what the Map and Filter operators do when their function
+// returns an error.
+type ErrorOpt func(*errorOpts) error
+
+// OnErrorAbort returns the error from Pour or Next, ending the stream. This
+// is the default.
+func OnErrorAbort() ErrorOpt {
+	return ErrorOpt(func(opts *errorOpts) error {
+		opts.action = errorAbort
+		return nil
+	})
+}
+
+// OnErrorSkip drops values the function failed on.
+func OnErrorSkip() ErrorOpt {
+	return ErrorOpt(func(opts *errorOpts) error {
+		opts.action = errorSkip
+		return nil
+	})
+}
+
+// OnErrorDeadLetter pours a DeadLetter into sink for every value the function
+// failed on and carries on with the next one.
+func OnErrorDeadLetter(sink luigi.Sink) ErrorOpt {
+	return ErrorOpt(func(opts *errorOpts) error {
+		if sink == nil {
+			return invalidf("nil dead letter sink")
+		}
+
+		opts.action = errorDeadLetter
+		opts.dead = sink
+		return nil
+	})
+}
+
+// OnErrorRetry calls the function again according to policy before giving
+// up. What happens then is set by the other options.
+func OnErrorRetry(policy luigi.RetryPolicy) ErrorOpt {
+	return ErrorOpt(func(opts *errorOpts) error {
+		opts.retry = policy
+		return nil
+	})
+}
+
+// WithStage sets the stage name of the DeadLetters poured by the operator.
+func WithStage(name string) ErrorOpt {
+	return ErrorOpt(func(opts *errorOpts) error {
+		opts.stage = name
+		return nil
+	})
+}
+
+func newErrorOpts(opts []ErrorOpt) errorOpts {
+	var eOpts errorOpts
+
+	for i, opt := range opts {
+		err := opt(&eOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid error option %d", i))
+		}
+	}
+
+	return eOpts
+}
+
+// handle calls f until it succeeds or the retry policy gives up, using
+// luigi.Retry. It returns whether v should be skipped, and the error that
+// ends the stream, if any. If ctx is cancelled while retrying, the last error
+// of f ends the stream, like with luigi.PumpLossless.
+func (opts *errorOpts) handle(ctx context.Context, v interface{}, f func() error) (bool, error) {
+	err := luigi.Retry(ctx, opts.retry, f)
+	if err == nil {
+		return false, nil
+	} else if opts.retry != nil && ctx.Err() != nil {
+		return false, err
+	}
+
+	switch opts.action {
+	case errorSkip:
+		return true, nil
+	case errorDeadLetter:
+		dl := DeadLetter{Value: v, Err: err, Stage: opts.stage}
+		if dErr := opts.dead.Pour(ctx, dl); dErr != nil {
+			return false, errors.Wrap(dErr, "luigi: pour to dead letter sink failed")
+		}
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// mapValue applies f to v according to the options.
+func (opts *errorOpts) mapValue(ctx context.Context, f MapFunc, v interface{}) (out interface{}, skip bool, err error) {
+	skip, err = opts.handle(ctx, v, func() (err error) {
+		out, err = f(ctx, v)
+		return err
+	})
+
+	return out, skip, err
+}
+
+// filterValue applies f to v according to the options. Skipped values do not
+// pass.
+func (opts *errorOpts) filterValue(ctx context.Context, f FilterFunc, v interface{}) (bool, error) {
+	var pass bool
+	skip, err := opts.handle(ctx, v, func() (err error) {
+		pass, err = f(ctx, v)
+		return err
+	})
+
+	return pass && !skip, err
+}
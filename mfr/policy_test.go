@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func atoi(_ context.Context, v interface{}) (interface{}, error) {
+	return strconv.Atoi(v.(string))
+}
+
+func TestErrorPolicies(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	in := []interface{}{"1", "two", "3"}
+
+	src := luigi.SliceSource(in)
+	mapped := SourceMap(&src, atoi)
+	vs, err := luigi.NextBatch(ctx, mapped, 3)
+	r.NoError(err)
+	r.Equal([]interface{}{1}, vs, "the values before the error should be returned")
+	_, err = luigi.NextBatch(ctx, mapped, 3)
+	r.Error(err, "abort should be the default")
+
+	src = luigi.SliceSource(in)
+	var out []interface{}
+	r.NoError(luigi.Pump(ctx, luigi.NewSliceSink(&out), SourceMap(&src, atoi, OnErrorSkip())))
+	r.Equal([]interface{}{1, 3}, out)
+
+	var dead []interface{}
+	out = nil
+	sink := SinkMap(luigi.NewSliceSink(&out), atoi, OnErrorDeadLetter(luigi.NewSliceSink(&dead)), WithStage("parse"))
+	for _, v := range in {
+		r.NoError(sink.Pour(ctx, v))
+	}
+	r.Equal([]interface{}{1, 3}, out)
+	r.Len(dead, 1)
+	dl := dead[0].(DeadLetter)
+	r.Equal("two", dl.Value)
+	r.Equal("parse", dl.Stage)
+	var numErr *strconv.NumError
+	r.True(errors.As(dl.Err, &numErr), "expected the MapFunc's error, got %v", dl.Err)
+
+	out = nil
+	isEven := func(_ context.Context, v interface{}) (bool, error) {
+		i, err := strconv.Atoi(v.(string))
+		return i%2 == 0, err
+	}
+	filtered := SinkFilter(luigi.NewSliceSink(&out), isEven, OnErrorSkip())
+	r.NoError(luigi.PourBatch(ctx, filtered, []interface{}{"2", "three", "4"}))
+	r.Equal([]interface{}{"2", "4"}, out)
+}
+
+func TestErrorRetry(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var calls int
+	flaky := func(_ context.Context, v interface{}) (interface{}, error) {
+		calls++
+		if calls%3 != 0 {
+			return nil, errors.New("transient failure")
+		}
+		return v, nil
+	}
+
+	var out []interface{}
+	sink := SinkMap(luigi.NewSliceSink(&out), flaky, OnErrorRetry(luigi.RetryN(2, 0)))
+	r.NoError(sink.Pour(ctx, 1))
+	r.Equal([]interface{}{1}, out)
+	r.Equal(3, calls)
+
+	calls = 0
+	sink = SinkMap(luigi.NewSliceSink(&out), flaky, OnErrorRetry(luigi.RetryN(1, 0)))
+	r.EqualError(sink.Pour(ctx, 2), "transient failure", "should abort once retries are used up")
+	r.Equal(2, calls)
+}
+
+func TestErrorRetryCancel(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	failErr := errors.New("transient failure")
+	failing := func(_ context.Context, v interface{}) (interface{}, error) {
+		cancel()
+		return nil, failErr
+	}
+
+	var out, dead []interface{}
+	sink := SinkMap(luigi.NewSliceSink(&out), failing,
+		OnErrorRetry(luigi.RetryN(3, time.Hour)), OnErrorDeadLetter(luigi.NewSliceSink(&dead)))
+	r.Equal(failErr, sink.Pour(ctx, 1), "the last error should end the stream once cancelled, like in PumpLossless")
+	r.Empty(dead)
+
+	var pumped []interface{}
+	src := luigi.SliceSource([]interface{}{1})
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err := luigi.PumpLossless(ctx, SinkMap(luigi.NewSliceSink(&pumped), failing), &src, luigi.WithRetry(luigi.RetryN(3, time.Hour)))
+	var pErr *luigi.PourError
+	r.True(errors.As(err, &pErr), "got %v", err)
+	r.Equal(failErr, pErr.Err)
+}
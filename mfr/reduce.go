@@ -6,7 +6,6 @@ package mfr // import "github.com/ssbc/go-luigi/mfr"
 
 import (
 	"context"
-	"errors"
 	"sync"
 
 	"github.com/ssbc/go-luigi"
@@ -43,7 +42,7 @@ func (sink *reduceSink) Pour(ctx context.Context, v interface{}) error {
 	defer sink.l.Unlock()
 
 	if sink.closed {
-		return errors.New("write to closed sink")
+		return luigi.ErrPourToClosedSink
 	}
 
 	acc, err := sink.Value()
@@ -65,7 +64,7 @@ func (sink *reduceSink) Close() error {
 	defer sink.l.Unlock()
 
 	if sink.closed {
-		return errors.New("closing closed sink")
+		return luigi.ErrCloseClosedSink
 	}
 
 	sink.closed = true
@@ -74,5 +73,5 @@ func (sink *reduceSink) Close() error {
 
 // Set retuns an error. All writes to the observable are performed by the sink.
 func (sink *reduceSink) Set(interface{}) error {
-	return errors.New("read-only observable")
+	return luigi.ErrReadOnlyObservable
 }
@@ -6,6 +6,7 @@ package mfr // import "github.com/ssbc/go-luigi/mfr"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -118,3 +119,25 @@ func TestReduce(t_ *testing.T) {
 		t_.Run(fmt.Sprint(i), mkTest(tc))
 	}
 }
+
+func TestReduceErrors(t *testing.T) {
+	sink := NewReduceSink(func(_ context.Context, acc, v interface{}) (interface{}, error) {
+		return v, nil
+	})
+
+	if err := sink.Set(1); !errors.Is(err, luigi.ErrReadOnly) {
+		t.Errorf("expected read-only error, got %v", err)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sink.Pour(context.Background(), 1); !errors.Is(err, luigi.ErrClosed) {
+		t.Errorf("expected closed error, got %v", err)
+	}
+
+	if err := sink.Close(); err != luigi.ErrCloseClosedSink {
+		t.Errorf("expected %v, got %v", luigi.ErrCloseClosedSink, err)
+	}
+}
@@ -0,0 +1,165 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+
+	"github.com/ssbc/go-luigi/typed"
+)
+
+// TypedMapFunc is used to convert or 'map' values of type In to values of
+// type Out.
+type TypedMapFunc[In, Out any] func(context.Context, In) (Out, error)
+
+// TypedSinkMap returns a Sink which writes converted values to its argument
+// according to a given TypedMapFunc.
+func TypedSinkMap[In, Out any](sink typed.Sink[Out], f TypedMapFunc[In, Out]) typed.Sink[In] {
+	return &typedSinkMap[In, Out]{
+		Sink: sink,
+		f:    f,
+	}
+}
+
+type typedSinkMap[In, Out any] struct {
+	typed.Sink[Out]
+	f TypedMapFunc[In, Out]
+}
+
+// Pour implements the typed.Sink interface.
+func (sink *typedSinkMap[In, Out]) Pour(ctx context.Context, v In) error {
+	out, err := sink.f(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return sink.Sink.Pour(ctx, out)
+}
+
+// TypedSourceMap returns a new Source which produces converted values
+// according to a given TypedMapFunc.
+func TypedSourceMap[In, Out any](src typed.Source[In], f TypedMapFunc[In, Out]) typed.Source[Out] {
+	return &typedSrcMap[In, Out]{
+		src: src,
+		f:   f,
+	}
+}
+
+type typedSrcMap[In, Out any] struct {
+	src typed.Source[In]
+	f   TypedMapFunc[In, Out]
+}
+
+// Next implements the typed.Source interface.
+func (src *typedSrcMap[In, Out]) Next(ctx context.Context) (Out, error) {
+	v, err := src.src.Next(ctx)
+	if err != nil {
+		var zero Out
+		return zero, err
+	}
+
+	return src.f(ctx, v)
+}
+
+// TypedFilterFunc is used as a predicate to select values of type T in a stream.
+type TypedFilterFunc[T any] func(ctx context.Context, v T) (bool, error)
+
+// TypedSinkFilter returns a new Sink whose values are selected according to
+// the given TypedFilterFunc.
+func TypedSinkFilter[T any](sink typed.Sink[T], f TypedFilterFunc[T]) typed.Sink[T] {
+	return &typedSinkFilter[T]{
+		Sink: sink,
+		f:    f,
+	}
+}
+
+type typedSinkFilter[T any] struct {
+	typed.Sink[T]
+	f TypedFilterFunc[T]
+}
+
+// Pour implements the typed.Sink interface.
+func (sink *typedSinkFilter[T]) Pour(ctx context.Context, v T) error {
+	pass, err := sink.f(ctx, v)
+	if err == nil && pass {
+		err = sink.Sink.Pour(ctx, v)
+	}
+
+	return err
+}
+
+// TypedSourceFilter returns a new Source whose values are filtered according
+// to the given TypedFilterFunc.
+func TypedSourceFilter[T any](src typed.Source[T], f TypedFilterFunc[T]) typed.Source[T] {
+	return &typedSrcFilter[T]{
+		Source: src,
+		f:      f,
+	}
+}
+
+type typedSrcFilter[T any] struct {
+	typed.Source[T]
+	f TypedFilterFunc[T]
+}
+
+// Next implements the typed.Source interface.
+func (src *typedSrcFilter[T]) Next(ctx context.Context) (v T, err error) {
+	var (
+		pass bool
+		zero T
+	)
+
+	for !pass {
+		v, err = src.Source.Next(ctx)
+		if err != nil {
+			return zero, err
+		}
+
+		pass, err = src.f(ctx, v)
+		if err != nil {
+			return zero, err
+		}
+	}
+
+	return v, nil
+}
+
+// TypedReduceFunc reduces a value v and an accumulator to the next
+// accumulator value.
+type TypedReduceFunc[Acc, V any] func(ctx context.Context, acc Acc, v V) (Acc, error)
+
+// TypedReduceSink reduces values of type V into an accumulator of type Acc
+// that can be observed using the Observable methods.
+type TypedReduceSink[Acc, V any] interface {
+	typed.Sink[V]
+	typed.Observable[Acc]
+}
+
+// NewTypedReduceSink returns a TypedReduceSink that uses the passed reduce
+// function. The accumulator starts out as the zero value of Acc.
+func NewTypedReduceSink[Acc, V any](f TypedReduceFunc[Acc, V]) TypedReduceSink[Acc, V] {
+	rs := NewReduceSink(func(ctx context.Context, acc, v interface{}) (interface{}, error) {
+		tAcc, err := typed.Convert[Acc](acc)
+		if err != nil {
+			return nil, err
+		}
+		tV, err := typed.Convert[V](v)
+		if err != nil {
+			return nil, err
+		}
+
+		return f(ctx, tAcc, tV)
+	})
+
+	return &typedReduceSink[Acc, V]{
+		Observable: typed.FromObservable[Acc](rs),
+		Sink:       typed.FromSink[V](rs),
+	}
+}
+
+type typedReduceSink[Acc, V any] struct {
+	typed.Observable[Acc]
+	typed.Sink[V]
+}
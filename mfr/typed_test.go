@@ -0,0 +1,86 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package mfr // import "github.com/ssbc/go-luigi/mfr"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-luigi/typed"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleTypedSourceMap() {
+	toRune := func(_ context.Context, v int) (rune, error) {
+		return rune(v + 97), nil
+	}
+
+	numbers := typed.SliceSource[int]{0, 1, 2, 3, 4}
+	runes := TypedSourceMap[int, rune](&numbers, toRune)
+
+	for {
+		v, err := runes.Next(context.Background())
+		if luigi.IsEOS(err) {
+			break
+		}
+		fmt.Print(string(v))
+	}
+	// Output: abcde
+}
+
+func TestTyped(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	isEven := func(_ context.Context, v int) (bool, error) {
+		return v%2 == 0, nil
+	}
+	itoa := func(_ context.Context, v int) (string, error) {
+		return strconv.Itoa(v), nil
+	}
+
+	var out []string
+	sink := TypedSinkFilter[int](TypedSinkMap[int, string](typed.NewSliceSink(&out), itoa), isEven)
+
+	numbers := typed.SliceSource[int]{1, 2, 3, 4, 5, 6}
+	r.NoError(typed.Pump[int](ctx, sink, TypedSourceFilter[int](&numbers, func(_ context.Context, v int) (bool, error) {
+		return v > 2, nil
+	})))
+	r.Equal([]string{"4", "6"}, out)
+
+	sum := NewTypedReduceSink(func(_ context.Context, acc int, v int) (int, error) {
+		return acc + v, nil
+	})
+
+	numbers = typed.SliceSource[int]{1, 2, 3, 4}
+	r.NoError(typed.Pump[int](ctx, sum, &numbers))
+
+	total, err := sum.Value()
+	r.NoError(err)
+	r.Equal(10, total)
+
+	r.Error(sum.Set(0), "reduce sink observable should be read-only")
+}
+
+func TestTypedReduceSinkTypeError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sum := NewTypedReduceSink(func(_ context.Context, acc int, v int) (int, error) {
+		return acc + v, nil
+	})
+
+	// reach the untyped reduce sink to pour a value of the wrong type
+	untyped := typed.ToSink[int](sum.(*typedReduceSink[int, int]).Sink)
+	err := untyped.Pour(ctx, "one")
+
+	var typeErr typed.TypeError
+	r.True(errors.As(err, &typeErr), "expected a TypeError, got %v", err)
+	r.Equal("one", typeErr.Got)
+}
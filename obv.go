@@ -7,6 +7,8 @@ package luigi // import "github.com/ssbc/go-luigi"
 import (
 	"context"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
 // TODO should Observable Set and Value get a ctx?
@@ -24,7 +26,9 @@ type Observable interface {
 	Value() (interface{}, error)
 }
 
-// NewObservable returns a new Observable
+// NewObservable returns a new Observable. It is a FilteredBroadcast, so use
+// RegisterWithOpts or AsErrorBroadcast to learn whether the current value
+// could be poured into a new sink.
 func NewObservable(v interface{}) Observable {
 	bcstSink, bcst := NewBroadcast()
 
@@ -61,7 +65,8 @@ func (o *observable) Value() (interface{}, error) {
 	return o.v, nil
 }
 
-// Register implements the Broadcast interface.
+// Register implements the Broadcast interface. The current value is poured
+// into sink in the background. If that fails, sink is closed with the error.
 func (o *observable) Register(sink Sink) func() {
 	o.Lock() // is released when goroutine finishes
 
@@ -79,12 +84,9 @@ func (o *observable) Register(sink Sink) func() {
 		defer o.Unlock()
 
 		err := sink.Pour(ctx, v)
-		// TODO at least log this error...or find out what to do with it. maybe
-		// change Broadcast interface to let Register return an error?
 		if err != nil {
-			// TODO maybe also handle the error from Close...or just let it slip
-			// because we can't handle it anyway?
-			sink.Close()
+			// nobody is left to report a failing close to
+			closeWithError(sink, err)
 			return
 		}
 
@@ -104,3 +106,43 @@ func (o *observable) Register(sink Sink) func() {
 		}
 	}
 }
+
+// RegisterWithOpts implements the FilteredBroadcast interface. Unlike
+// Register, it pours the current value before returning, using the context
+// set using WithRegisterContext. Set is held up until then. If that fails,
+// sink is closed with the error and the error is returned. After Close, sink
+// is closed without pouring and ErrBroadcastClosed is returned.
+func (o *observable) RegisterWithOpts(sink Sink, opts ...RegisterOpt) (func(), error) {
+	bcst := o.Broadcast.(*broadcast)
+	sub, err := bcst.newSubscriber(sink, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Lock()
+	defer o.Unlock()
+
+	if bcst.isClosed() {
+		bcst.closeLate(sink)
+		return nil, ErrBroadcastClosed
+	}
+
+	ctx := sub.registerCtx()
+	v, ok, err := bcst.accept(ctx, sub, o.v)
+	if err == nil && ok {
+		err = sink.Pour(ctx, v)
+	}
+	if err != nil {
+		closeWithError(sink, err)
+		return nil, errors.Wrap(err, "luigi: pouring current value failed")
+	}
+
+	cancel, err := bcst.add(sub)
+	if err != nil {
+		// closed while pouring
+		bcst.closeLate(sink)
+		return nil, err
+	}
+
+	return cancel, nil
+}
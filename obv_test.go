@@ -6,9 +6,13 @@ package luigi // import "github.com/ssbc/go-luigi"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestObservable(t *testing.T) {
@@ -94,3 +98,76 @@ func TestObservable(t *testing.T) {
 		test(tc)
 	}
 }
+
+func TestObservableRegisterError(t *testing.T) {
+	r := require.New(t)
+
+	failErr := errors.New("sink failed")
+	closeErr := make(chan error, 1)
+	var failing FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if err != nil {
+			closeErr <- err
+			return nil
+		}
+		return failErr
+	}
+
+	obv := NewObservable(1)
+	cancel := obv.Register(failing)
+	r.Equal(failErr, <-closeErr, "the sink should be closed with the error")
+	cancel()
+
+	_, err := AsErrorBroadcast(obv.(FilteredBroadcast)).Register(failing)
+	r.True(errors.Is(err, failErr), "the error should be returned")
+	r.Equal(failErr, <-closeErr)
+
+	var out []interface{}
+	cancel, err = RegisterWithOpts(obv, NewSliceSink(&out))
+	r.NoError(err)
+	r.Equal([]interface{}{1}, out, "the current value should be poured before returning")
+	r.NoError(obv.Set(2))
+	cancel()
+	r.Equal([]interface{}{1, 2}, out)
+}
+
+func TestObservableRegisterPipe(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	obv := NewObservable(1)
+
+	regCtx, cancelCtx := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelCtx()
+	src, pipe := NewPipe()
+	_, err := RegisterWithOpts(obv, pipe, WithRegisterContext(regCtx))
+	r.True(errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	_, err = src.Next(ctx)
+	r.True(errors.Is(err, context.DeadlineExceeded), "the sink should be closed with the error, got %v", err)
+	r.NoError(obv.Set(2), "Set should not be held up anymore")
+
+	src, pipe = NewPipe()
+	next := make(chan interface{}, 1)
+	go func() {
+		v, _ := src.Next(ctx)
+		next <- v
+	}()
+	cancel, err := RegisterWithOpts(obv, pipe)
+	r.NoError(err)
+	defer cancel()
+	r.Equal(2, <-next, "the current value should be read from the pipe")
+}
+
+func TestObservableRegisterClosed(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	obv := NewObservable(1)
+	r.NoError(obv.(*observable).sink.Close())
+
+	src, pipe := NewPipe(WithBuffer(1))
+	_, err := RegisterWithOpts(obv, pipe)
+	r.Equal(ErrBroadcastClosed, err)
+
+	_, err = src.Next(ctx)
+	r.True(IsEOS(err), "the sink should be closed without a value, got %v", err)
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"time"
+)
+
+// OverflowPolicy decides what a pipe does with a value that is poured while
+// its buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits until there is room in the buffer. This is the
+	// default.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowFail makes Pour return ErrBufferFull.
+	OverflowFail
+
+	// OverflowDropNewest drops the value being poured.
+	OverflowDropNewest
+
+	// OverflowDropOldest evicts the oldest buffered value to make room, which
+	// turns the buffer into a ring buffer. It requires a buffer of at least
+	// one value.
+	OverflowDropOldest
+)
+
+var (
+	// ErrBufferFull is returned by Pour on pipes using OverflowFail.
+	ErrBufferFull = &Error{Kind: ErrWouldBlock, Msg: "luigi: pipe buffer full"}
+
+	// ErrPourTimeout is returned by Pour if the timeout set using
+	// WithPourTimeout expired.
+	ErrPourTimeout = &Error{Kind: ErrTimeout, Msg: "luigi: pour timed out"}
+)
+
+// WithOverflow sets what happens when values are poured into a pipe whose
+// buffer is full. Policies other than OverflowBlock can't be used together
+// with NonBlocking or WithUnboundedBuffer.
+func WithOverflow(policy OverflowPolicy) PipeOpt {
+	return PipeOpt(func(opts *pipeOpts) error {
+		if policy < OverflowBlock || policy > OverflowDropOldest {
+			return invalidf("unknown overflow policy %d", policy)
+		}
+
+		opts.overflow = policy
+		return nil
+	})
+}
+
+// WithPourTimeout limits how long Pour waits for room in the buffer when
+// using OverflowBlock. Once it expires, ErrPourTimeout is returned. It can't
+// be used together with NonBlocking or WithUnboundedBuffer.
+func WithPourTimeout(d time.Duration) PipeOpt {
+	return PipeOpt(func(opts *pipeOpts) error {
+		opts.pourTimeout = d
+		return nil
+	})
+}
+
+// OnDrop registers a function that is called with every value dropped by the
+// overflow policy.
+func OnDrop(f func(v interface{})) PipeOpt {
+	return PipeOpt(func(opts *pipeOpts) error {
+		opts.onDrop = f
+		return nil
+	})
+}
+
+// DropCounter is implemented by sinks that may drop values.
+type DropCounter interface {
+	// Dropped returns the number of values dropped so far.
+	Dropped() uint64
+}
+
+// Dropped implements the DropCounter interface.
+func (sink *chanSink) Dropped() uint64 {
+	var stats PipeStats
+	sink.stats.fill(&stats)
+	return stats.Dropped
+}
+
+func (sink *chanSink) drop(v interface{}) {
+	sink.stats.addDropped(1)
+	if sink.onDrop != nil {
+		sink.onDrop(v)
+	}
+}
+
+// pourOverflow pours v according to the overflow policy of a blocking sink.
+func (sink *chanSink) pourOverflow(ctx context.Context, v interface{}) error {
+	switch sink.overflow {
+	case OverflowFail:
+		select {
+		case sink.ch <- v:
+			return nil
+		default:
+			return ErrBufferFull
+		}
+
+	case OverflowDropNewest:
+		select {
+		case sink.ch <- v:
+		default:
+			sink.drop(v)
+		}
+		return nil
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case sink.ch <- v:
+				return nil
+			default:
+			}
+
+			select {
+			case old := <-sink.evict:
+				sink.drop(old)
+			default:
+			}
+		}
+	}
+
+	select {
+	case sink.ch <- v:
+		return nil
+	default:
+	}
+
+	start := time.Now()
+	defer func() {
+		sink.stats.addBlocked(time.Since(start))
+	}()
+
+	var timeout <-chan time.Time
+	if sink.pourTimeout > 0 {
+		t := time.NewTimer(sink.pourTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case sink.ch <- v:
+		return nil
+	case <-sink.closeCh:
+		return ErrPourToClosedSink
+	case <-timeout:
+		return ErrPourTimeout
+	case <-ctx.Done():
+		// we may be called with closed context on a closed sink. in that case we want to return the closed sink error.
+		select {
+		case <-sink.closeCh:
+			return ErrPourToClosedSink
+		default:
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// drain reads all values from src until it ends.
+func drain(t *testing.T, src Source) []interface{} {
+	var out []interface{}
+	for {
+		v, err := src.Next(context.Background())
+		if IsEOS(err) {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, v)
+	}
+}
+
+func TestOverflowFail(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(2), WithOverflow(OverflowFail))
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2))
+	r.Equal(ErrBufferFull, sink.Pour(ctx, 3))
+
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{1, 2}, drain(t, src))
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var dropped []interface{}
+	src, sink := NewPipe(WithBuffer(2), WithOverflow(OverflowDropNewest), OnDrop(func(v interface{}) {
+		dropped = append(dropped, v)
+	}))
+
+	for i := 1; i <= 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{1, 2}, drain(t, src))
+	r.Equal([]interface{}{3, 4, 5}, dropped)
+	r.Equal(uint64(3), sink.(DropCounter).Dropped())
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var dropped []interface{}
+	src, sink := NewPipe(WithBuffer(2), WithOverflow(OverflowDropOldest), OnDrop(func(v interface{}) {
+		dropped = append(dropped, v)
+	}))
+
+	for i := 1; i <= 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{4, 5}, drain(t, src))
+	r.Equal([]interface{}{1, 2, 3}, dropped)
+	r.Equal(uint64(3), sink.(DropCounter).Dropped())
+
+	r.Panics(func() { NewPipe(WithOverflow(OverflowDropOldest)) }, "needs a buffer")
+}
+
+func TestPourTimeout(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(1), WithPourTimeout(10*time.Millisecond))
+
+	r.NoError(sink.Pour(ctx, 1))
+
+	start := time.Now()
+	r.Equal(ErrPourTimeout, sink.Pour(ctx, 2))
+	r.True(time.Since(start) >= 10*time.Millisecond, "returned too early")
+
+	r.NoError(sink.Close())
+	r.Equal([]interface{}{1}, drain(t, src))
+}
+
+func TestOverflowInvalidCombinations(t *testing.T) {
+	r := require.New(t)
+
+	invalid := func(opts ...PipeOpt) (err error) {
+		defer func() { err, _ = recover().(error) }()
+		NewPipe(opts...)
+		return nil
+	}
+
+	for name, opts := range map[string][]PipeOpt{
+		"non-blocking overflow":  {NonBlocking(), WithBuffer(1), WithOverflow(OverflowDropNewest)},
+		"non-blocking timeout":   {NonBlocking(), WithPourTimeout(time.Second)},
+		"unbounded overflow":     {WithUnboundedBuffer(), WithOverflow(OverflowFail)},
+		"unbounded pour timeout": {WithUnboundedBuffer(), WithPourTimeout(time.Second)},
+	} {
+		err := invalid(opts...)
+		r.True(errors.Is(err, ErrInvalid), "%s should be rejected, got %v", name, err)
+	}
+
+	r.NoError(invalid(NonBlocking(), WithOverflow(OverflowBlock)), "the default policy should be accepted")
+}
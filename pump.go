@@ -0,0 +1,230 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PourError is returned by PumpLossless when a value could not be poured into
+// the sink. It carries the undelivered value so it isn't lost.
+type PourError struct {
+	// Value is the value that could not be delivered.
+	Value interface{}
+
+	// Err is the error returned by the last call to Pour.
+	Err error
+
+	// Requeued is true if the value was put back into the source.
+	Requeued bool
+}
+
+func (err *PourError) Error() string {
+	return fmt.Sprintf("luigi: failed to pour value: %v", err.Err)
+}
+
+// Cause returns the error returned by Pour.
+func (err *PourError) Cause() error { return err.Err }
+
+// Unwrap returns the error returned by Pour.
+func (err *PourError) Unwrap() error { return err.Err }
+
+// Unreader is implemented by sources that allow putting back values, so they
+// are returned by the next call to Next.
+type Unreader interface {
+	Unread(v interface{}) error
+}
+
+// UnreadSource is a Source that values can be put back into.
+type UnreadSource interface {
+	Source
+	Unreader
+}
+
+// NewUnreadSource wraps src such that values can be put back into it. Unread
+// values are returned in last-in first-out order before reading from src
+// again.
+func NewUnreadSource(src Source) UnreadSource {
+	return &unreadSource{src: src}
+}
+
+type unreadSource struct {
+	src Source
+
+	l       sync.Mutex
+	pending []interface{}
+}
+
+// Next implements the Source interface.
+func (src *unreadSource) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	if n := len(src.pending); n > 0 {
+		v := src.pending[n-1]
+		src.pending = src.pending[:n-1]
+		src.l.Unlock()
+		return v, nil
+	}
+	src.l.Unlock()
+
+	return src.src.Next(ctx)
+}
+
+// Unread implements the Unreader interface.
+func (src *unreadSource) Unread(v interface{}) error {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	src.pending = append(src.pending, v)
+	return nil
+}
+
+// RetryPolicy decides whether a failed operation should be tried again. It is
+// passed the number of attempts made so far and the last error, and returns
+// how long to wait before the next attempt and whether to make one.
+type RetryPolicy func(attempt int, err error) (wait time.Duration, retry bool)
+
+// RetryN returns a RetryPolicy that allows up to n retries, waiting the given
+// duration before each.
+func RetryN(n int, wait time.Duration) RetryPolicy {
+	return func(attempt int, _ error) (time.Duration, bool) {
+		return wait, attempt <= n
+	}
+}
+
+// Backoff returns a RetryPolicy that allows up to n retries. It waits base
+// before the first one and doubles the wait after each, up to max.
+func Backoff(n int, base, max time.Duration) RetryPolicy {
+	return func(attempt int, _ error) (time.Duration, bool) {
+		wait := base
+		for i := 1; i < attempt && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+
+		return wait, attempt <= n
+	}
+}
+
+// Jitter returns a RetryPolicy that randomly shortens or lengthens the waits
+// of policy by up to the given fraction, so that clients failing at the same
+// time don't all retry at the same time.
+func Jitter(policy RetryPolicy, frac float64) RetryPolicy {
+	return func(attempt int, err error) (time.Duration, bool) {
+		wait, retry := policy(attempt, err)
+		wait += time.Duration((2*rand.Float64() - 1) * frac * float64(wait))
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, retry
+	}
+}
+
+type pumpOpts struct {
+	retry   RetryPolicy
+	requeue bool
+}
+
+// PumpOpt configures PumpLossless' behavior
+type PumpOpt func(*pumpOpts) error
+
+// WithRetry sets the policy used to retry failed Pour calls.
+func WithRetry(policy RetryPolicy) PumpOpt {
+	return PumpOpt(func(opts *pumpOpts) error {
+		opts.retry = policy
+		return nil
+	})
+}
+
+// WithRequeue makes PumpLossless put undelivered values back into the source
+// if it implements Unreader.
+func WithRequeue() PumpOpt {
+	return PumpOpt(func(opts *pumpOpts) error {
+		opts.requeue = true
+		return nil
+	})
+}
+
+// PumpLossless moves values from a source into a sink, like Pump, but never
+// silently drops a value when Pour fails.
+//
+// A failed Pour is retried according to the policy set using WithRetry. If
+// the value still can't be delivered, a *PourError carrying the value is
+// returned. With WithRequeue, the value is also put back into the source.
+//
+// PushSources are read using Next, since Push can't report undelivered values.
+func PumpLossless(ctx context.Context, dst Sink, src Source, opts ...PumpOpt) error {
+	var pOpts pumpOpts
+
+	for i, opt := range opts {
+		err := opt(&pOpts)
+		if err != nil {
+			return errors.Wrapf(err, "luigi: invalid pump option %d", i)
+		}
+	}
+
+	for {
+		request(src, dst)
+		v, err := src.Next(ctx)
+		if IsEOS(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		err = pourRetry(ctx, dst, v, pOpts.retry)
+		if err == nil {
+			continue
+		}
+
+		pErr := &PourError{Value: v, Err: err}
+		if unreader, ok := src.(Unreader); ok && pOpts.requeue {
+			if uErr := unreader.Unread(v); uErr == nil {
+				pErr.Requeued = true
+			}
+		}
+
+		return pErr
+	}
+}
+
+func pourRetry(ctx context.Context, dst Sink, v interface{}, policy RetryPolicy) error {
+	return Retry(ctx, policy, func() error {
+		return dst.Pour(ctx, v)
+	})
+}
+
+// Retry calls f until it succeeds or policy gives up, waiting in between as
+// policy says, and returns the last error of f. If ctx is cancelled while
+// waiting, that is returned as well. A nil policy calls f once.
+func Retry(ctx context.Context, policy RetryPolicy, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || policy == nil {
+			return err
+		}
+
+		wait, retry := policy(attempt, err)
+		if !retry {
+			return err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+	}
+}
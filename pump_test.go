@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// flakySink fails the first n Pour calls for every value.
+func flakySink(n int, out *[]interface{}) Sink {
+	var fails int
+	return FuncSink(func(ctx context.Context, v interface{}, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if fails < n {
+			fails++
+			return errors.New("transient failure")
+		}
+
+		fails = 0
+		*out = append(*out, v)
+		return nil
+	})
+}
+
+func TestPumpLosslessRetry(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var out []interface{}
+	src := SliceSource([]interface{}{1, 2, 3})
+
+	err := PumpLossless(ctx, flakySink(2, &out), &src, WithRetry(RetryN(2, time.Millisecond)))
+	r.NoError(err)
+	r.Equal([]interface{}{1, 2, 3}, out)
+}
+
+func TestPumpLosslessRequeue(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var out []interface{}
+	slice := SliceSource([]interface{}{1, 2, 3})
+	src := NewUnreadSource(&slice)
+
+	err := PumpLossless(ctx, flakySink(2, &out), src, WithRetry(RetryN(1, 0)), WithRequeue())
+
+	var pErr *PourError
+	r.True(errors.As(err, &pErr), "expected PourError, got %v", err)
+	r.Equal(1, pErr.Value)
+	r.True(pErr.Requeued)
+	r.EqualError(errors.Unwrap(err), "transient failure")
+
+	// the value wasn't lost and is delivered on the next run
+	err = PumpLossless(ctx, flakySink(0, &out), src)
+	r.NoError(err)
+	r.Equal([]interface{}{1, 2, 3}, out)
+}
+
+func TestPumpLosslessNoRetry(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var out []interface{}
+	src := SliceSource([]interface{}{1, 2, 3})
+
+	err := PumpLossless(ctx, flakySink(1, &out), &src, WithRequeue())
+
+	var pErr *PourError
+	r.True(errors.As(err, &pErr), "expected PourError, got %v", err)
+	r.Equal(1, pErr.Value)
+	r.False(pErr.Requeued, "SliceSource doesn't implement Unreader")
+	r.Empty(out)
+}
+
+func TestBackoff(t *testing.T) {
+	r := require.New(t)
+
+	policy := Backoff(4, time.Millisecond, 5*time.Millisecond)
+	for attempt, want := range []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 5 * time.Millisecond} {
+		wait, retry := policy(attempt+1, nil)
+		r.True(retry)
+		r.Equal(want, wait)
+	}
+
+	_, retry := policy(5, nil)
+	r.False(retry)
+}
+
+func TestRetryCancel(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	failErr := errors.New("transient failure")
+	var calls int
+	err := Retry(ctx, RetryN(3, time.Hour), func() error {
+		calls++
+		cancel()
+		return failErr
+	})
+	r.Equal(failErr, err, "the last error should be returned once cancelled")
+	r.Equal(1, calls)
+}
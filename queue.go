@@ -0,0 +1,226 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// ErrHighWaterMark can be returned by the function passed to
+// WithHighWaterMark to reject values.
+var ErrHighWaterMark = &Error{Kind: ErrTooSlow, Msg: "luigi: pipe queue exceeds high-water mark"}
+
+// WithUnboundedBuffer backs the pipe with a queue that grows as needed
+// instead of a fixed-size channel, so Pour never blocks. The buffer size is
+// ignored, and it can't be used together with WithOverflow or
+// WithPourTimeout.
+func WithUnboundedBuffer() PipeOpt {
+	return PipeOpt(func(opts *pipeOpts) error {
+		opts.unbounded = true
+		return nil
+	})
+}
+
+// WithHighWaterMark sets a threshold for the queue of a pipe created with
+// WithUnboundedBuffer. When a Pour grows the queue beyond n values, f is
+// called with the new length. If f returns an error, the value is not queued
+// and Pour returns the error. f is called with the queue locked, so it must
+// not use the pipe.
+func WithHighWaterMark(n int, f func(length int) error) PipeOpt {
+	return PipeOpt(func(opts *pipeOpts) error {
+		if n < 0 {
+			return invalidf("negative high-water mark %d", n)
+		}
+
+		opts.highWaterMark = n
+		opts.onHighWaterMark = f
+		return nil
+	})
+}
+
+// queue is the state shared by both ends of an unbounded pipe.
+type queue struct {
+	l        sync.Mutex
+	vs       []interface{}
+	closeErr error
+
+	// ready is signalled when values are added
+	ready   chan struct{}
+	closeCh chan struct{}
+
+	highWaterMark   int
+	onHighWaterMark func(int) error
+
+	stats *pipeCounters
+}
+
+func newQueuePipe(pOpts pipeOpts) (Source, Sink) {
+	q := &queue{
+		ready:           make(chan struct{}, 1),
+		closeCh:         make(chan struct{}),
+		highWaterMark:   pOpts.highWaterMark,
+		onHighWaterMark: pOpts.onHighWaterMark,
+		stats:           newPipeCounters(),
+	}
+
+	return &queueSource{queue: q, nonBlocking: pOpts.nonBlocking}, &queueSink{queue: q}
+}
+
+// signal wakes up a waiting reader, if any.
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// push appends vs to the queue. It must be called with the lock held.
+func (q *queue) push(vs ...interface{}) error {
+	select {
+	case <-q.closeCh:
+		return ErrPourToClosedSink
+	default:
+	}
+
+	if n := len(q.vs) + len(vs); q.highWaterMark > 0 && n > q.highWaterMark && q.onHighWaterMark != nil {
+		if err := q.onHighWaterMark(n); err != nil {
+			return err
+		}
+	}
+
+	q.vs = append(q.vs, vs...)
+	q.stats.addPoured(len(vs))
+	q.signal()
+	return nil
+}
+
+// pop removes up to max values from the queue. If it is empty and closed, the
+// closing error is returned, or EOS if there is none. It must be called with
+// the lock held.
+func (q *queue) pop(max int) ([]interface{}, error) {
+	if len(q.vs) == 0 {
+		select {
+		case <-q.closeCh:
+			if q.closeErr == nil {
+				return nil, EOS{}
+			}
+			return nil, q.closeErr
+		default:
+			return nil, nil
+		}
+	}
+
+	if max > len(q.vs) {
+		max = len(q.vs)
+	}
+
+	vs := make([]interface{}, max)
+	copy(vs, q.vs)
+	for i := 0; i < max; i++ {
+		q.vs[i] = nil
+	}
+	q.vs = q.vs[max:]
+	q.stats.addRead(max)
+
+	// wake up the next reader
+	if len(q.vs) > 0 {
+		q.signal()
+	}
+
+	return vs, nil
+}
+
+type queueSource struct {
+	*queue
+	nonBlocking bool
+}
+
+// Next implements the Source interface.
+func (src *queueSource) Next(ctx context.Context) (interface{}, error) {
+	vs, err := src.NextBatch(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return vs[0], nil
+}
+
+// NextBatch implements the BatchSource interface.
+func (src *queueSource) NextBatch(ctx context.Context, max int) ([]interface{}, error) {
+	if max < 1 {
+		max = 1
+	}
+
+	for {
+		src.l.Lock()
+		vs, err := src.pop(max)
+		src.l.Unlock()
+		if err != nil || vs != nil {
+			return vs, err
+		}
+
+		if src.nonBlocking {
+			return nil, ErrNotReadyForReading
+		}
+
+		select {
+		case <-src.ready:
+		case <-src.closeCh:
+		case <-ctx.Done():
+			// even if both the context is cancelled and the stream is closed,
+			// we consistently return the closing error
+			select {
+			case <-src.closeCh:
+			default:
+				return nil, errors.Wrap(ctx.Err(), "luigi next done")
+			}
+		}
+	}
+}
+
+type queueSink struct {
+	*queue
+	closeOnce sync.Once
+}
+
+// Pour implements the Sink interface. It never blocks.
+func (sink *queueSink) Pour(ctx context.Context, v interface{}) error {
+	sink.l.Lock()
+	defer sink.l.Unlock()
+
+	return sink.push(v)
+}
+
+// PourBatch implements the BatchSink interface.
+func (sink *queueSink) PourBatch(ctx context.Context, vs []interface{}) error {
+	sink.l.Lock()
+	defer sink.l.Unlock()
+
+	return sink.push(vs...)
+}
+
+// Demand implements the Demander interface. An unbounded pipe takes any
+// number of values.
+func (sink *queueSink) Demand() int {
+	return Unbounded
+}
+
+// Close implements the Sink interface.
+func (sink *queueSink) Close() error {
+	return sink.CloseWithError(EOS{})
+}
+
+func (sink *queueSink) CloseWithError(err error) error {
+	sink.closeOnce.Do(func() {
+		sink.l.Lock()
+		sink.closeErr = err
+		close(sink.closeCh)
+		sink.l.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnboundedPipe(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithUnboundedBuffer())
+	r.Equal(Unbounded, Demand(sink))
+
+	var in []interface{}
+	for i := 0; i < 10000; i++ {
+		r.NoError(sink.Pour(ctx, i))
+		in = append(in, i)
+	}
+	r.NoError(sink.Close())
+	r.Equal(ErrPourToClosedSink, sink.Pour(ctx, -1))
+
+	r.Equal(in, drain(t, src))
+
+	// stays closed
+	_, err := src.Next(ctx)
+	r.Equal(EOS{}, err)
+}
+
+func TestUnboundedPipeCloseWithError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithUnboundedBuffer())
+	testErr := errors.New("test error")
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.(ErrorCloser).CloseWithError(testErr))
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	_, err = src.Next(ctx)
+	r.Equal(testErr, err)
+}
+
+func TestUnboundedPipeCloseWithNilError(t *testing.T) {
+	for name, opts := range map[string][]PipeOpt{
+		"blocking":     {WithUnboundedBuffer()},
+		"non-blocking": {WithUnboundedBuffer(), NonBlocking()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+			ctx := context.Background()
+
+			src, sink := NewPipe(opts...)
+			r.NoError(sink.(ErrorCloser).CloseWithError(nil))
+
+			_, err := src.Next(ctx)
+			r.Equal(EOS{}, err, "a nil error should end the stream")
+		})
+	}
+}
+
+func TestUnboundedPipeBlockingNext(t *testing.T) {
+	r := require.New(t)
+
+	src, sink := NewPipe(WithUnboundedBuffer())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := src.Next(ctx)
+	r.Equal(context.DeadlineExceeded, errors.Cause(err), "expected deadline exceeded")
+
+	done := make(chan error)
+	go func() {
+		v, err := src.Next(context.Background())
+		if err == nil && v != "hello" {
+			err = errors.New("unexpected value")
+		}
+		done <- err
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	r.NoError(sink.Pour(context.Background(), "hello"))
+	r.NoError(<-done)
+
+	go func() {
+		_, err := src.Next(context.Background())
+		done <- err
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	r.NoError(sink.Close())
+	r.Equal(EOS{}, <-done)
+}
+
+func TestUnboundedPipeHighWaterMark(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	var warnings []int
+	_, sink := NewPipe(WithUnboundedBuffer(), WithHighWaterMark(2, func(n int) error {
+		warnings = append(warnings, n)
+		if n > 3 {
+			return ErrHighWaterMark
+		}
+		return nil
+	}))
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2))
+	r.NoError(sink.Pour(ctx, 3))
+	r.Equal(ErrHighWaterMark, sink.Pour(ctx, 4))
+	r.Equal([]int{3, 4}, warnings)
+}
+
+func TestUnboundedPipeObservable(t *testing.T) {
+	r := require.New(t)
+
+	obv := NewObservable(0)
+	src, sink := NewPipe(WithUnboundedBuffer())
+
+	cancel := obv.Register(sink)
+	for i := 1; i <= 100; i++ {
+		// doesn't deadlock although nobody reads yet
+		r.NoError(obv.Set(i))
+	}
+	cancel()
+
+	out := drain(t, src)
+	r.Len(out, 101)
+	r.Equal(100, out[100])
+}
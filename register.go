@@ -0,0 +1,175 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"reflect"
+)
+
+// FilteredBroadcast is a Broadcast that can select and convert the values
+// for each registered sink before delivering them.
+type FilteredBroadcast interface {
+	Broadcast
+
+	// RegisterWithOpts is like Register, but accepts options. It returns an
+	// error if they are invalid.
+	RegisterWithOpts(dst Sink, opts ...RegisterOpt) (func(), error)
+}
+
+// RegisterWithOpts registers dst on bcst using the given options. It fails if
+// options are passed and bcst is not a FilteredBroadcast.
+func RegisterWithOpts(bcst Broadcast, dst Sink, opts ...RegisterOpt) (func(), error) {
+	if fbcst, ok := bcst.(FilteredBroadcast); ok {
+		return fbcst.RegisterWithOpts(dst, opts...)
+	}
+
+	if len(opts) > 0 {
+		return nil, invalidf("luigi: broadcast does not support register options")
+	}
+
+	return bcst.Register(dst), nil
+}
+
+// ErrorBroadcast is a broadcast whose Register reports why a sink could not
+// be registered. Failures after registering are reported using
+// OnDeliveryError.
+type ErrorBroadcast interface {
+	// Register registers dst for updates. It fails if the options are
+	// invalid, if the broadcast is closed or if dst could not be brought up
+	// to date.
+	Register(dst Sink, opts ...RegisterOpt) (func(), error)
+}
+
+// NewErrorBroadcast is like NewBroadcast, but returns an ErrorBroadcast.
+func NewErrorBroadcast(opts ...BroadcastOpt) (Sink, ErrorBroadcast) {
+	sink, bcst := NewBroadcast(opts...)
+	return sink, AsErrorBroadcast(bcst.(FilteredBroadcast))
+}
+
+// AsErrorBroadcast returns an ErrorBroadcast that registers on bcst.
+func AsErrorBroadcast(bcst FilteredBroadcast) ErrorBroadcast {
+	return errorBroadcast{bcst}
+}
+
+type errorBroadcast struct {
+	bcst FilteredBroadcast
+}
+
+// Register implements the ErrorBroadcast interface.
+func (eb errorBroadcast) Register(dst Sink, opts ...RegisterOpt) (func(), error) {
+	return eb.bcst.RegisterWithOpts(dst, opts...)
+}
+
+type registerOpts struct {
+	filter func(context.Context, interface{}) (bool, error)
+	mapf   func(context.Context, interface{}) (interface{}, error)
+	key    interface{}
+	hasKey bool
+	ctx    context.Context
+}
+
+// RegisterOpt configures a registration on a FilteredBroadcast
+type RegisterOpt func(*registerOpts) error
+
+// WithFilter only delivers the values f returns true for. It has the same
+// signature as mfr.FilterFunc.
+func WithFilter(f func(ctx context.Context, v interface{}) (bool, error)) RegisterOpt {
+	return RegisterOpt(func(opts *registerOpts) error {
+		if f == nil {
+			return invalidf("nil filter")
+		}
+
+		opts.filter = f
+		return nil
+	})
+}
+
+// WithMap delivers the values returned by f, after filtering. It has the same
+// signature as mfr.MapFunc.
+func WithMap(f func(ctx context.Context, v interface{}) (interface{}, error)) RegisterOpt {
+	return RegisterOpt(func(opts *registerOpts) error {
+		if f == nil {
+			return invalidf("nil map func")
+		}
+
+		opts.mapf = f
+		return nil
+	})
+}
+
+// WithKey only delivers the values whose key, as returned by the func set
+// using WithKeyFunc, equals key. The sinks are indexed by key, so this is
+// cheaper than a filter when there are many of them.
+func WithKey(key interface{}) RegisterOpt {
+	return RegisterOpt(func(opts *registerOpts) error {
+		if key != nil && !reflect.TypeOf(key).Comparable() {
+			return invalidf("key of type %T is not comparable", key)
+		}
+
+		opts.key = key
+		opts.hasKey = true
+		return nil
+	})
+}
+
+// WithRegisterContext sets the context used while bringing the sink up to
+// date before it is registered, like for the replay of NewReplayBroadcast.
+// Registering fails if it is cancelled before that is done.
+func WithRegisterContext(ctx context.Context) RegisterOpt {
+	return RegisterOpt(func(opts *registerOpts) error {
+		if ctx == nil {
+			return invalidf("nil context")
+		}
+
+		opts.ctx = ctx
+		return nil
+	})
+}
+
+// WithKeyFunc sets the func that returns the key of a value, which is
+// matched against the keys sinks were registered with using WithKey. It is
+// called once per value and must return comparable keys.
+func WithKeyFunc(f func(ctx context.Context, v interface{}) (interface{}, error)) BroadcastOpt {
+	return BroadcastOpt(func(opts *broadcastOpts) error {
+		if f == nil {
+			return invalidf("nil key func")
+		}
+
+		opts.keyFunc = f
+		return nil
+	})
+}
+
+// registerCtx returns the context set using WithRegisterContext, or the
+// background context.
+func (opts *registerOpts) registerCtx() context.Context {
+	if opts.ctx == nil {
+		return context.Background()
+	}
+
+	return opts.ctx
+}
+
+// prepare applies the filter and map func to v. It returns false if v should
+// not be delivered.
+func (opts *registerOpts) prepare(ctx context.Context, v interface{}) (interface{}, bool, error) {
+	if opts.filter != nil {
+		pass, err := opts.filter(ctx, v)
+		if err != nil || !pass {
+			return nil, false, err
+		}
+	}
+
+	if opts.mapf != nil {
+		var err error
+		v, err = opts.mapf(ctx, v)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	return v, true, nil
+}
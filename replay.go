@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// NewReplayBroadcast is like NewBroadcast, but keeps the last n values poured
+// into the sink, or those poured within the given window, and pours them into
+// every newly registered sink before any new value. A limit that is zero or
+// less is not applied, but at least one is required.
+//
+// Register returns once the replay is done, but Pour is not held up by it.
+// The values poured in the meantime are buffered and poured into the sink
+// after the replay, so registered sinks see every value exactly once. If more
+// than n values, or DefaultBatchSize if that is larger, are buffered for a
+// sink, it is closed with ErrReplayLagging and not registered. The replay
+// uses the context set using WithRegisterContext. A sink that fails during
+// the replay is closed with the error and not registered, and
+// RegisterWithOpts returns the error. Sinks registered after Close are closed
+// after the replay, with the error passed to CloseWithError if any, and
+// RegisterWithOpts returns ErrBroadcastClosed.
+//
+// The returned Broadcast is a FilteredBroadcast, and the register options
+// apply to the replayed values as well. The window is measured using the
+// Clock set using WithReplayClock.
+func NewReplayBroadcast(n int, window time.Duration, opts ...BroadcastOpt) (Sink, Broadcast) {
+	if n <= 0 && window <= 0 {
+		panic(invalidf("luigi: replay broadcast needs a limit"))
+	}
+
+	sink, bcst := NewBroadcast(opts...)
+	rb := &replayBroadcast{
+		sink:     sink,
+		bcst:     bcst.(*broadcast),
+		n:        n,
+		window:   window,
+		catching: make(map[*replayCatchUp]struct{}),
+	}
+
+	return (*replaySink)(rb), rb
+}
+
+// WithReplayClock sets the Clock used by NewReplayBroadcast to measure its
+// window. The default is RealClock.
+func WithReplayClock(clk Clock) BroadcastOpt {
+	return BroadcastOpt(func(opts *broadcastOpts) error {
+		if clk == nil {
+			return invalidf("nil clock")
+		}
+
+		opts.clock = clk
+		return nil
+	})
+}
+
+type replayEntry struct {
+	v interface{}
+	t time.Time
+}
+
+type replayBroadcast struct {
+	sink Sink
+	bcst *broadcast
+
+	n      int
+	window time.Duration
+
+	// l is held while pouring and while registering, but not while
+	// replaying
+	l        sync.Mutex
+	history  []replayEntry
+	catching map[*replayCatchUp]struct{}
+	closed   bool
+	closeErr error
+}
+
+// ErrReplayLagging is returned when registering on a replay broadcast if the
+// sink fell too far behind while being replayed to.
+var ErrReplayLagging = &Error{Kind: ErrTooSlow, Msg: "luigi: sink fell behind during replay"}
+
+// replayCatchUp buffers the values poured while a sink is being replayed to.
+type replayCatchUp struct {
+	vs  []interface{}
+	max int
+
+	// lagging is set instead of buffering more than max values
+	lagging bool
+}
+
+// prune drops the values that should not be replayed anymore. It must be
+// called with the lock held.
+func (rb *replayBroadcast) prune(now time.Time) {
+	var drop int
+	for drop < len(rb.history) {
+		if rb.n > 0 && len(rb.history)-drop > rb.n {
+			drop++
+			continue
+		}
+		if rb.window > 0 && !rb.history[drop].t.After(now.Add(-rb.window)) {
+			drop++
+			continue
+		}
+		break
+	}
+
+	for i := 0; i < drop; i++ {
+		rb.history[i] = replayEntry{}
+	}
+	rb.history = rb.history[drop:]
+}
+
+// Register implements the Broadcast interface.
+func (rb *replayBroadcast) Register(sink Sink) func() {
+	// replay errors can't be reported here
+	cancel, err := rb.RegisterWithOpts(sink)
+	if err != nil {
+		// the sink has been closed already
+		return func() {}
+	}
+
+	return cancel
+}
+
+// RegisterWithOpts implements the FilteredBroadcast interface.
+func (rb *replayBroadcast) RegisterWithOpts(sink Sink, opts ...RegisterOpt) (func(), error) {
+	sub, err := rb.bcst.newSubscriber(sink, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rb.l.Lock()
+	rb.prune(rb.bcst.opts.clock.Now())
+	vs := make([]interface{}, len(rb.history))
+	for i, e := range rb.history {
+		vs[i] = e.v
+	}
+	catchUp := &replayCatchUp{max: rb.n}
+	if catchUp.max < DefaultBatchSize {
+		catchUp.max = DefaultBatchSize
+	}
+	rb.catching[catchUp] = struct{}{}
+	rb.l.Unlock()
+
+	ctx := sub.registerCtx()
+	for {
+		for _, v := range vs {
+			v, ok, err := rb.bcst.accept(ctx, sub, v)
+			if err == nil && ok {
+				err = sink.Pour(ctx, v)
+			}
+			if err != nil {
+				rb.l.Lock()
+				delete(rb.catching, catchUp)
+				rb.l.Unlock()
+
+				err = errors.Wrap(err, "luigi: replay failed")
+				closeWithError(sink, err)
+				return nil, err
+			}
+		}
+
+		rb.l.Lock()
+		if catchUp.lagging {
+			rb.l.Unlock()
+
+			closeWithError(sink, ErrReplayLagging)
+			return nil, ErrReplayLagging
+		}
+		if len(catchUp.vs) == 0 {
+			// the lock is held until the sink is registered
+			break
+		}
+		vs, catchUp.vs = catchUp.vs, nil
+		rb.l.Unlock()
+	}
+	defer rb.l.Unlock()
+
+	delete(rb.catching, catchUp)
+	if rb.closed {
+		if rb.closeErr != nil {
+			closeWithError(sink, rb.closeErr)
+		} else {
+			sink.Close()
+		}
+		return nil, ErrBroadcastClosed
+	}
+
+	return rb.bcst.add(sub)
+}
+
+type replaySink replayBroadcast
+
+// Pour implements the Sink interface.
+func (rs *replaySink) Pour(ctx context.Context, v interface{}) error {
+	rs.l.Lock()
+	defer rs.l.Unlock()
+
+	now := rs.bcst.opts.clock.Now()
+	rs.history = append(rs.history, replayEntry{v: v, t: now})
+	(*replayBroadcast)(rs).prune(now)
+	for catchUp := range rs.catching {
+		if len(catchUp.vs) == catchUp.max {
+			catchUp.vs, catchUp.lagging = nil, true
+			delete(rs.catching, catchUp)
+			continue
+		}
+		catchUp.vs = append(catchUp.vs, v)
+	}
+
+	return rs.sink.Pour(ctx, v)
+}
+
+// Close implements the Sink interface.
+func (rs *replaySink) Close() error {
+	rs.l.Lock()
+	defer rs.l.Unlock()
+
+	rs.closed = true
+	return rs.sink.Close()
+}
+
+// CloseWithError implements the ErrorCloser interface.
+func (rs *replaySink) CloseWithError(err error) error {
+	rs.l.Lock()
+	defer rs.l.Unlock()
+
+	rs.closed = true
+	rs.closeErr = err
+	return closeWithError(rs.sink, err)
+}
@@ -0,0 +1,36 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+// The tests in this file use ltime, which imports luigi.
+package luigi_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/ssbc/go-luigi/ltime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayBroadcastWindow(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	clk := ltime.NewFakeClock(time.Unix(0, 0))
+	sink, bcst := luigi.NewReplayBroadcast(0, 20*time.Millisecond, luigi.WithReplayClock(clk))
+	r.NoError(sink.Pour(ctx, 1))
+	clk.Advance(30 * time.Millisecond)
+	r.NoError(sink.Pour(ctx, 2))
+
+	var out []interface{}
+	bcst.Register(luigi.NewSliceSink(&out))
+	r.Equal([]interface{}{2}, out, "1 should have left the window")
+
+	clk.Advance(30 * time.Millisecond)
+	out = nil
+	bcst.Register(luigi.NewSliceSink(&out))
+	r.Empty(out, "2 should have left the window")
+}
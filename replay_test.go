@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayBroadcast(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(2, 0)
+	for i := 0; i < 4; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	var out []interface{}
+	cancel := bcst.Register(NewSliceSink(&out))
+	r.Equal([]interface{}{2, 3}, out, "the last two values should be replayed")
+
+	r.NoError(sink.Pour(ctx, 4))
+	r.Equal([]interface{}{2, 3, 4}, out)
+	cancel()
+
+	r.NoError(sink.Close())
+	out = nil
+	bcst.Register(NewSliceSink(&out))
+	r.Equal([]interface{}{3, 4}, out, "late sinks should get the replay")
+}
+
+func TestReplayBroadcastConcurrent(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const n = 1000
+	sink, bcst := NewReplayBroadcast(n, 0)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			sink.Pour(ctx, i)
+		}
+	}()
+
+	// registering while values are poured must not lose or repeat any
+	outs := make([][]interface{}, 10)
+	for i := range outs {
+		bcst.Register(NewSliceSink(&outs[i]))
+	}
+	wg.Wait()
+
+	for _, out := range outs {
+		r.Len(out, n)
+		for i, v := range out {
+			r.Equal(i, v)
+		}
+	}
+}
+
+func TestReplayBroadcastFiltered(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(10, 0)
+	for i := 0; i < 4; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	even := func(_ context.Context, v interface{}) (bool, error) {
+		return v.(int)%2 == 0, nil
+	}
+
+	var out []interface{}
+	_, err := RegisterWithOpts(bcst, NewSliceSink(&out), WithFilter(even))
+	r.NoError(err)
+	r.NoError(sink.Pour(ctx, 4))
+	r.NoError(sink.Pour(ctx, 5))
+	r.Equal([]interface{}{0, 2, 4}, out, "the filter should apply to the replay as well")
+}
+
+func TestReplayBroadcastPipe(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(3, 0)
+	for i := 0; i < 3; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+
+	src, pipe := NewPipe()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := bcst.(FilteredBroadcast).RegisterWithOpts(pipe)
+		errc <- err
+	}()
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	out := []interface{}{v}
+
+	// the replay is blocked on the unbuffered pipe, which must not hold up
+	// new values
+	r.NoError(sink.Pour(ctx, 3))
+	r.NoError(sink.Pour(ctx, 4))
+
+	go func() {
+		errc <- sink.Pour(ctx, 5)
+	}()
+
+	for len(out) < 6 {
+		v, err := src.Next(ctx)
+		r.NoError(err)
+		out = append(out, v)
+	}
+	r.NoError(<-errc)
+	r.NoError(<-errc)
+	r.Equal([]interface{}{0, 1, 2, 3, 4, 5}, out, "no values should be lost or repeated")
+}
+
+func TestReplayBroadcastRegisterContext(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(3, 0)
+	r.NoError(sink.Pour(ctx, 1))
+
+	regCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	src, pipe := NewPipe()
+	_, err := bcst.(FilteredBroadcast).RegisterWithOpts(pipe, WithRegisterContext(regCtx))
+	r.True(errors.Is(err, context.DeadlineExceeded), "got %v", err)
+
+	_, err = src.Next(ctx)
+	r.True(errors.Is(err, context.DeadlineExceeded), "the sink should be closed with the error, got %v", err)
+
+	r.NoError(sink.Pour(ctx, 2), "pouring should not be affected")
+}
+
+func TestReplayBroadcastClosed(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(2, 0)
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Close())
+
+	src, pipe := NewPipe(WithBuffer(2))
+	_, err := bcst.(FilteredBroadcast).RegisterWithOpts(pipe)
+	r.Equal(ErrBroadcastClosed, err)
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v, "the sink should still get the replay")
+	_, err = src.Next(ctx)
+	r.True(IsEOS(err), "the sink should be closed, got %v", err)
+}
+
+func TestReplayBroadcastLagging(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	sink, bcst := NewReplayBroadcast(1, 0)
+	r.NoError(sink.Pour(ctx, 0))
+
+	started, resume := make(chan struct{}), make(chan struct{})
+	closed := make(chan error, 1)
+	var slow FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if err != nil {
+			closed <- err
+			return nil
+		}
+		if v == 0 {
+			close(started)
+			<-resume
+		}
+		return nil
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := bcst.(FilteredBroadcast).RegisterWithOpts(slow)
+		errc <- err
+	}()
+
+	<-started
+	for i := 1; i <= DefaultBatchSize+1; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+	close(resume)
+
+	r.Equal(ErrReplayLagging, <-errc)
+	r.Equal(ErrReplayLagging, <-closed, "the sink should be closed with the error")
+}
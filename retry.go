@@ -0,0 +1,215 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReconnectEvent is set on the Observable returned by RetrySource whenever
+// the underlying source fails or has been recreated.
+type ReconnectEvent struct {
+	// Attempt is the number of failures since the last value was read.
+	Attempt int
+
+	// Err is the error that caused the reconnect. It is nil once connected.
+	Err error
+
+	// Wait is how long RetrySource waits before calling the factory again.
+	Wait time.Duration
+
+	// Connected is true if the factory returned a new source.
+	Connected bool
+}
+
+// CursorFunc returns the position of a value in the stream, which allows
+// continuing after it.
+type CursorFunc func(v interface{}) interface{}
+
+type retryOpts struct {
+	cursor CursorFunc
+}
+
+// RetryOpt configures RetrySource's behavior
+type RetryOpt func(*retryOpts) error
+
+// WithCursor records the position of every value read from the source. The
+// factory can get the position of the last value using Cursor.
+func WithCursor(f CursorFunc) RetryOpt {
+	return RetryOpt(func(opts *retryOpts) error {
+		if f == nil {
+			return invalidf("nil cursor func")
+		}
+
+		opts.cursor = f
+		return nil
+	})
+}
+
+type cursorKey struct{}
+
+// Cursor returns the position of the last value read by RetrySource from the
+// context passed to its factory. It returns false if no value has been read
+// yet or WithCursor was not used.
+func Cursor(ctx context.Context) (interface{}, bool) {
+	c, ok := ctx.Value(cursorKey{}).(cursor)
+	return c.v, ok
+}
+
+type cursor struct{ v interface{} }
+
+// RetrySource returns a Source that reads from the source returned by f and
+// calls f again when that fails with an error other than EOS. The policy
+// decides how long to wait before that and when to give up, in which case
+// the returned Source fails with the last error. Failures to create the
+// source are retried as well.
+//
+// Reconnects are reported as ReconnectEvents on the returned Observable. If
+// the failed source has a Close method it is called before f.
+func RetrySource(f func(context.Context) (Source, error), policy RetryPolicy, opts ...RetryOpt) (Source, Observable) {
+	var rOpts retryOpts
+
+	for i, opt := range opts {
+		err := opt(&rOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid retry option %d", i))
+		}
+	}
+
+	src := &retrySource{
+		f:      f,
+		policy: policy,
+		opts:   rOpts,
+		events: NewObservable(nil),
+	}
+
+	return src, src.events
+}
+
+type retrySource struct {
+	f      func(context.Context) (Source, error)
+	policy RetryPolicy
+	opts   retryOpts
+	events Observable
+
+	l         sync.Mutex
+	cur       Source
+	attempt   int
+	retryAt   time.Time
+	cursor    interface{}
+	hasCursor bool
+	err       error
+}
+
+// Next implements the Source interface. The events are published and the
+// waits between attempts happen without holding the lock, so observers may
+// take their time or even call Next.
+func (src *retrySource) Next(ctx context.Context) (interface{}, error) {
+	for {
+		v, retry, wait, events, err := src.step(ctx)
+		for _, ev := range events {
+			src.events.Set(ev)
+		}
+
+		if !retry {
+			return v, err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, errors.Wrap(ctx.Err(), "luigi next done")
+		}
+	}
+}
+
+// step tries to read a value, creating the source first if needed. If that
+// fails and should be retried, it returns true and how long to wait before
+// the next step. It also returns the events to publish.
+func (src *retrySource) step(ctx context.Context) (v interface{}, retry bool, wait time.Duration, events []ReconnectEvent, err error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	if src.err != nil {
+		return nil, false, 0, nil, src.err
+	}
+
+	if src.cur == nil {
+		// another call may have failed while this one was waiting
+		if wait := time.Until(src.retryAt); wait > 0 {
+			return nil, true, wait, nil, nil
+		}
+
+		fCtx := ctx
+		if src.hasCursor {
+			fCtx = context.WithValue(ctx, cursorKey{}, cursor{src.cursor})
+		}
+
+		cur, err := src.f(fCtx)
+		if err != nil {
+			wait, ev, err := src.backoff(err)
+			return nil, err == nil, wait, []ReconnectEvent{ev}, err
+		}
+
+		src.cur = cur
+		if src.attempt > 0 {
+			events = append(events, ReconnectEvent{Attempt: src.attempt, Connected: true})
+		}
+	}
+
+	v, err = src.cur.Next(ctx)
+	if err == nil {
+		src.attempt = 0
+		if src.opts.cursor != nil {
+			src.cursor, src.hasCursor = src.opts.cursor(v), true
+		}
+		return v, false, 0, events, nil
+	}
+
+	// neither the end of the stream nor cancellation are connection failures
+	if IsEOS(err) {
+		src.err = err
+		return nil, false, 0, events, err
+	} else if ctx.Err() != nil {
+		return nil, false, 0, events, err
+	}
+
+	if closer, ok := src.cur.(interface{ Close() error }); ok {
+		closer.Close()
+	}
+	src.cur = nil
+
+	wait, ev, err := src.backoff(err)
+	return nil, err == nil, wait, append(events, ev), err
+}
+
+// backoff records the failure err and returns how long to wait before the
+// next attempt, or an error if there should be none. It must be called with
+// the lock held.
+func (src *retrySource) backoff(err error) (time.Duration, ReconnectEvent, error) {
+	src.attempt++
+
+	var (
+		wait  time.Duration
+		retry bool
+	)
+	if src.policy != nil {
+		wait, retry = src.policy(src.attempt, err)
+	}
+
+	if !retry {
+		src.err = err
+		return 0, ReconnectEvent{Attempt: src.attempt, Err: err}, err
+	}
+
+	src.retryAt = time.Now().Add(wait)
+	return wait, ReconnectEvent{Attempt: src.attempt, Err: err, Wait: wait}, nil
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetrySource(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	data := []interface{}{0, 1, 2, 3, 4, 5}
+	connErr := errors.New("connection lost")
+
+	// every connection delivers two values and then fails
+	var starts []interface{}
+	factory := func(ctx context.Context) (Source, error) {
+		start := 0
+		if c, ok := Cursor(ctx); ok {
+			start = c.(int) + 1
+		}
+		starts = append(starts, start)
+
+		i := start
+		return FuncSource(func(context.Context) (interface{}, error) {
+			if i == len(data) {
+				return nil, EOS{}
+			}
+			if i == start+2 {
+				return nil, connErr
+			}
+			i++
+			return data[i-1], nil
+		}), nil
+	}
+
+	src, events := RetrySource(factory, RetryN(1, time.Millisecond), WithCursor(func(v interface{}) interface{} {
+		return v
+	}))
+
+	var evs []interface{}
+	cancel := events.Register(NewSliceSink(&evs))
+	defer cancel()
+
+	var out []interface{}
+	r.NoError(Pump(ctx, NewSliceSink(&out), src))
+	r.Equal(data, out)
+	r.Equal([]interface{}{0, 2, 4}, starts, "should resume after the last value")
+
+	r.Equal([]interface{}{
+		nil,
+		ReconnectEvent{Attempt: 1, Err: connErr, Wait: time.Millisecond},
+		ReconnectEvent{Attempt: 1, Connected: true},
+		ReconnectEvent{Attempt: 1, Err: connErr, Wait: time.Millisecond},
+		ReconnectEvent{Attempt: 1, Connected: true},
+	}, evs)
+}
+
+func TestRetrySourceGiveUp(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	dialErr := errors.New("connection refused")
+	var calls int
+	src, _ := RetrySource(func(context.Context) (Source, error) {
+		calls++
+		return nil, dialErr
+	}, Jitter(Backoff(2, time.Millisecond, time.Millisecond), 0.5))
+
+	_, err := src.Next(ctx)
+	r.Equal(dialErr, err)
+	r.Equal(3, calls)
+
+	_, err = src.Next(ctx)
+	r.Equal(dialErr, err, "the error should be final")
+	r.Equal(3, calls)
+}
+
+func TestRetrySourceEventsUnlocked(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	dialErr := errors.New("connection refused")
+	src, events := RetrySource(func(context.Context) (Source, error) {
+		return nil, dialErr
+	}, RetryN(1, time.Millisecond))
+
+	var locked []bool
+	var observer FuncSink = func(_ context.Context, v interface{}, err error) error {
+		if _, ok := v.(ReconnectEvent); ok {
+			l := &src.(*retrySource).l
+			held := !l.TryLock()
+			if !held {
+				l.Unlock()
+			}
+			locked = append(locked, held)
+		}
+		return nil
+	}
+	cancel := events.Register(observer)
+	defer cancel()
+
+	_, err := src.Next(ctx)
+	r.Equal(dialErr, err)
+	r.Equal([]bool{false, false}, locked, "events should be published without holding the lock")
+}
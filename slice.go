@@ -6,7 +6,6 @@ package luigi
 
 import (
 	"context"
-	"errors"
 )
 
 // SliceSink binds Source methods to an interface array.
@@ -23,6 +22,21 @@ func (src *SliceSource) Next(context.Context) (v interface{}, err error) {
 	return v, nil
 }
 
+// NextBatch implements the BatchSource interface.
+func (src *SliceSource) NextBatch(_ context.Context, max int) (vs []interface{}, err error) {
+	if len(*src) == 0 {
+		return nil, EOS{}
+	}
+
+	if max > len(*src) {
+		max = len(*src)
+	}
+
+	vs, *src = (*src)[:max:max], (*src)[max:]
+
+	return vs, nil
+}
+
 // SliceSink binds Sink methods to an interface array.
 type SliceSink struct {
 	slice  *[]interface{}
@@ -40,12 +54,21 @@ func NewSliceSink(arg *[]interface{}) *SliceSink {
 // Pour implements the Sink interface.  It writes value to a destination Sink.
 func (sink *SliceSink) Pour(ctx context.Context, v interface{}) error {
 	if sink.closed {
-		return errors.New("pour to closed sink")
+		return ErrPourToClosedSink
 	}
 	*sink.slice = append(*sink.slice, v)
 	return nil
 }
 
+// PourBatch implements the BatchSink interface.
+func (sink *SliceSink) PourBatch(ctx context.Context, vs []interface{}) error {
+	if sink.closed {
+		return ErrPourToClosedSink
+	}
+	*sink.slice = append(*sink.slice, vs...)
+	return nil
+}
+
 // Close is a dummy method to implement the Sink interface.
 func (sink *SliceSink) Close() error {
 	sink.closed = true
@@ -0,0 +1,149 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PipeStats is a snapshot of the state of a pipe.
+type PipeStats struct {
+	// Len is the number of buffered values.
+	Len int
+
+	// Cap is the size of the buffer, or Unbounded.
+	Cap int
+
+	// Poured is the number of values accepted by Pour, including dropped ones.
+	Poured uint64
+
+	// Read is the number of values returned by Next.
+	Read uint64
+
+	// Dropped is the number of values dropped by the overflow policy.
+	Dropped uint64
+
+	// BlockedPour is the total time Pour spent waiting for room in the buffer.
+	BlockedPour time.Duration
+
+	// Closed is true once the sink has been closed.
+	Closed bool
+
+	// CloseErr is the error the pipe was closed with. It is EOS{} if it
+	// was closed using Close.
+	CloseErr error
+}
+
+// StatsReporter is implemented by both ends of pipes created using NewPipe.
+type StatsReporter interface {
+	Stats() PipeStats
+}
+
+// pipeCounters is shared by both ends of a pipe. Its methods are safe to call
+// on nil.
+type pipeCounters struct {
+	poured  uint64
+	read    uint64
+	dropped uint64
+	blocked int64
+}
+
+func newPipeCounters() *pipeCounters {
+	return &pipeCounters{}
+}
+
+func (pc *pipeCounters) addPoured(n int) {
+	if pc != nil {
+		atomic.AddUint64(&pc.poured, uint64(n))
+	}
+}
+
+func (pc *pipeCounters) addRead(n int) {
+	if pc != nil {
+		atomic.AddUint64(&pc.read, uint64(n))
+	}
+}
+
+func (pc *pipeCounters) addDropped(n int) {
+	if pc != nil {
+		atomic.AddUint64(&pc.dropped, uint64(n))
+	}
+}
+
+func (pc *pipeCounters) addBlocked(d time.Duration) {
+	if pc != nil {
+		atomic.AddInt64(&pc.blocked, int64(d))
+	}
+}
+
+// fill sets the counter fields of stats.
+func (pc *pipeCounters) fill(stats *PipeStats) {
+	if pc != nil {
+		stats.Poured = atomic.LoadUint64(&pc.poured)
+		stats.Read = atomic.LoadUint64(&pc.read)
+		stats.Dropped = atomic.LoadUint64(&pc.dropped)
+		stats.BlockedPour = time.Duration(atomic.LoadInt64(&pc.blocked))
+	}
+}
+
+// Stats implements the StatsReporter interface.
+func (src *chanSource) Stats() PipeStats {
+	stats := PipeStats{
+		Len: len(src.ch),
+		Cap: cap(src.ch),
+	}
+
+	src.stats.fill(&stats)
+	stats.Closed, stats.CloseErr = closeState(src.closeCh, src.closeLock, src.closeErr)
+
+	return stats
+}
+
+// Stats implements the StatsReporter interface.
+func (sink *chanSink) Stats() PipeStats {
+	stats := PipeStats{
+		Len: len(sink.ch),
+		Cap: cap(sink.ch),
+	}
+
+	sink.stats.fill(&stats)
+	stats.Closed, stats.CloseErr = closeState(sink.closeCh, sink.closeLock, sink.closeErr)
+
+	return stats
+}
+
+func closeState(closeCh chan struct{}, closeLock *sync.Mutex, closeErr *error) (bool, error) {
+	select {
+	case <-closeCh:
+		closeLock.Lock()
+		defer closeLock.Unlock()
+		return true, *closeErr
+	default:
+		return false, nil
+	}
+}
+
+// Stats implements the StatsReporter interface.
+func (q *queue) Stats() PipeStats {
+	q.l.Lock()
+	defer q.l.Unlock()
+
+	stats := PipeStats{
+		Len: len(q.vs),
+		Cap: Unbounded,
+	}
+
+	q.stats.fill(&stats)
+
+	select {
+	case <-q.closeCh:
+		stats.Closed, stats.CloseErr = true, q.closeErr
+	default:
+	}
+
+	return stats
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeStats(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithBuffer(2), WithOverflow(OverflowDropNewest))
+
+	r.NoError(sink.Pour(ctx, 1))
+	r.NoError(sink.Pour(ctx, 2))
+	r.NoError(sink.Pour(ctx, 3))
+
+	_, err := src.Next(ctx)
+	r.NoError(err)
+
+	stats := src.(StatsReporter).Stats()
+	r.Equal(PipeStats{Len: 1, Cap: 2, Poured: 3, Read: 1, Dropped: 1}, stats)
+	r.Equal(stats, sink.(StatsReporter).Stats())
+
+	testErr := errors.New("test error")
+	r.NoError(sink.(ErrorCloser).CloseWithError(testErr))
+
+	stats = src.(StatsReporter).Stats()
+	r.True(stats.Closed)
+	r.Equal(testErr, stats.CloseErr)
+}
+
+func TestPipeStatsBlockedPour(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe()
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		src.Next(ctx)
+	}()
+
+	r.NoError(sink.Pour(ctx, 1))
+
+	stats := sink.(StatsReporter).Stats()
+	r.True(stats.BlockedPour >= 10*time.Millisecond, "blocked for %v", stats.BlockedPour)
+	r.Equal(uint64(1), stats.Poured)
+}
+
+func TestUnboundedPipeStats(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe(WithUnboundedBuffer())
+
+	for i := 0; i < 5; i++ {
+		r.NoError(sink.Pour(ctx, i))
+	}
+	_, err := src.Next(ctx)
+	r.NoError(err)
+	r.NoError(sink.Close())
+
+	stats := src.(StatsReporter).Stats()
+	r.Equal(PipeStats{Len: 4, Cap: Unbounded, Poured: 5, Read: 1, Closed: true, CloseErr: EOS{}}, stats)
+}
@@ -18,12 +18,11 @@ type EOS struct{}
 
 func (_ EOS) Error() string { return "end of stream" }
 
-// IsEOS checks whether the error is due to a closed stream.
+// IsEOS checks whether the error is due to a closed stream. It looks through
+// wrapped errors using errors.As.
 func IsEOS(err error) bool {
-	err = errors.Cause(err)
-
-	_, ok := err.(EOS)
-	return ok
+	var eos EOS
+	return errors.As(err, &eos)
 }
 
 // Sink is the interface which wraps methods writing to a stream.
@@ -50,13 +49,15 @@ type PushSource interface {
 // Pump moves values from a source into a sink.
 //
 // Currently this doesn't work atomically, so if a Sink errors in the
-// Pour call, the value that was read from the source is lost.
+// Pour call, the value that was read from the source is lost. Use
+// PumpLossless if that is a problem.
 func Pump(ctx context.Context, dst Sink, src Source) error {
 	if psrc, ok := src.(PushSource); ok {
 		return psrc.Push(ctx, dst)
 	}
 
 	for {
+		request(src, dst)
 		v, err := src.Next(ctx)
 		if IsEOS(err) {
 			return nil
@@ -69,21 +70,21 @@ func Pump(ctx context.Context, dst Sink, src Source) error {
 			return err
 		}
 	}
-
-	panic("unreachable")
 }
 
 // Pump moves values from a source into a sink.
 // PumpWithStatus lets you include callbacks so you know when it's processing vs. waiting.
 //
 // Currently this doesn't work atomically, so if a Sink errors in the
-// Pour call, the value that was read from the source is lost.
+// Pour call, the value that was read from the source is lost. Use
+// PumpLossless if that is a problem.
 func PumpWithStatus(ctx context.Context, dst Sink, src Source, startWaiting func(), doneWaiting func(), startProcessing func(), doneProcessing func()) error {
 	if psrc, ok := src.(PushSource); ok {
 		return psrc.Push(ctx, dst)
 	}
 
 	for {
+		request(src, dst)
 		startWaiting()
 		v, err := src.Next(ctx)
 		doneWaiting()
@@ -100,7 +101,20 @@ func PumpWithStatus(ctx context.Context, dst Sink, src Source, startWaiting func
 			return err
 		}
 	}
-
-	panic("unreachable")
 }
 
+// request passes the demand of dst on to src, if it is a Requester. Since the
+// pump holds on to a value while pouring it, at least one value is requested.
+func request(src Source, dst Sink) {
+	req, ok := src.(Requester)
+	if !ok {
+		return
+	}
+
+	n := Demand(dst)
+	if n == 0 {
+		n = 1
+	}
+
+	req.Request(n)
+}
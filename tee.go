@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// LagPolicy decides what Tee does when a branch's buffer is full.
+type LagPolicy int
+
+const (
+	// LagBlock stops reading from the source until every branch has room.
+	// This is the default.
+	LagBlock LagPolicy = iota
+
+	// LagDrop drops values for branches that have no room.
+	LagDrop
+
+	// LagError fails branches that have no room with ErrLagging, once they
+	// have read their buffer.
+	LagError
+)
+
+// ErrLagging is returned by a Tee branch that fell behind when using LagError.
+var ErrLagging = &Error{Kind: ErrTooSlow, Msg: "luigi: tee branch fell behind"}
+
+type teeOpts struct {
+	bufferSize int
+	policy     LagPolicy
+}
+
+// TeeOpt configures Tee's behavior
+type TeeOpt func(*teeOpts) error
+
+// WithTeeBuffer sets the number of values buffered for each branch. It must
+// be at least one, which is the default.
+func WithTeeBuffer(bufSize int) TeeOpt {
+	return TeeOpt(func(opts *teeOpts) error {
+		if bufSize < 1 {
+			return invalidf("buffer size %d too small", bufSize)
+		}
+
+		opts.bufferSize = bufSize
+		return nil
+	})
+}
+
+// WithLagPolicy sets what happens when a branch falls behind.
+func WithLagPolicy(policy LagPolicy) TeeOpt {
+	return TeeOpt(func(opts *teeOpts) error {
+		if policy < LagBlock || policy > LagError {
+			return invalidf("unknown lag policy %d", policy)
+		}
+
+		opts.policy = policy
+		return nil
+	})
+}
+
+// TeeSource is a branch returned by Tee.
+type TeeSource interface {
+	Source
+
+	// Close detaches the branch from the tee and drops its buffer, so it no
+	// longer holds back the other branches.
+	Close() error
+}
+
+// Tee splits src into n branches that each return all values of src. Each
+// branch has its own buffer, so they can be read independently. Reading from
+// src happens in the Next call of whichever branch runs out of values first.
+func Tee(src Source, n int, opts ...TeeOpt) []TeeSource {
+	tOpts := teeOpts{bufferSize: 1}
+
+	for i, opt := range opts {
+		err := opt(&tOpts)
+		if err != nil {
+			panic(errors.Wrapf(err, "luigi: invalid tee option %d", i))
+		}
+	}
+
+	t := &tee{
+		src:     src,
+		opts:    tOpts,
+		changed: make(chan struct{}),
+	}
+
+	branches := make([]TeeSource, n)
+	for i := range branches {
+		b := &teeBranch{tee: t}
+		t.branches = append(t.branches, b)
+		branches[i] = b
+	}
+
+	return branches
+}
+
+type tee struct {
+	src  Source
+	opts teeOpts
+
+	l        sync.Mutex
+	branches []*teeBranch
+	reading  bool
+	srcErr   error
+
+	// changed is closed and replaced whenever a branch might be able to
+	// make progress
+	changed chan struct{}
+}
+
+// notify wakes up all waiting branches. It must be called with the lock held.
+func (t *tee) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// blocked returns whether a branch has no room for another value. It must be
+// called with the lock held.
+func (t *tee) blocked() bool {
+	if t.opts.policy != LagBlock {
+		return false
+	}
+
+	for _, b := range t.branches {
+		if b.live() && len(b.buf) >= t.opts.bufferSize {
+			return true
+		}
+	}
+
+	return false
+}
+
+// distribute adds v to the buffer of all branches. It must be called with the
+// lock held.
+func (t *tee) distribute(v interface{}) {
+	for _, b := range t.branches {
+		if !b.live() {
+			continue
+		}
+
+		if len(b.buf) < t.opts.bufferSize {
+			b.buf = append(b.buf, v)
+			continue
+		}
+
+		switch t.opts.policy {
+		case LagDrop:
+			b.dropped++
+		case LagError:
+			b.err = ErrLagging
+		}
+	}
+}
+
+type teeBranch struct {
+	*tee
+
+	buf     []interface{}
+	closed  bool
+	err     error
+	dropped uint64
+}
+
+// live returns whether the branch still receives values.
+func (b *teeBranch) live() bool {
+	return !b.closed && b.err == nil
+}
+
+// Next implements the Source interface.
+func (b *teeBranch) Next(ctx context.Context) (interface{}, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	for {
+		if len(b.buf) > 0 {
+			v := b.buf[0]
+			b.buf[0] = nil
+			b.buf = b.buf[1:]
+			b.notify()
+			return v, nil
+		}
+
+		if b.closed {
+			return nil, EOS{}
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		if b.srcErr != nil {
+			return nil, b.srcErr
+		}
+
+		if b.reading || b.blocked() {
+			changed := b.changed
+			b.l.Unlock()
+
+			select {
+			case <-changed:
+				b.l.Lock()
+				continue
+			case <-ctx.Done():
+				b.l.Lock()
+				return nil, errors.Wrap(ctx.Err(), "luigi next done")
+			}
+		}
+
+		b.reading = true
+		b.l.Unlock()
+		v, err := b.src.Next(ctx)
+		b.l.Lock()
+		b.reading = false
+		b.notify()
+
+		if err != nil {
+			// a cancelled context only affects this call
+			if ctx.Err() != nil && !IsEOS(err) {
+				return nil, err
+			}
+
+			b.srcErr = err
+			continue
+		}
+
+		b.distribute(v)
+	}
+}
+
+// Dropped implements the DropCounter interface.
+func (b *teeBranch) Dropped() uint64 {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	return b.dropped
+}
+
+// Close implements the TeeSource interface.
+func (b *teeBranch) Close() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	b.closed = true
+	b.buf = nil
+	b.notify()
+	return nil
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTee(t *testing.T) {
+	r := require.New(t)
+
+	in := make([]interface{}, 100)
+	for i := range in {
+		in[i] = i
+	}
+	src := SliceSource(append([]interface{}(nil), in...))
+
+	branches := Tee(&src, 3, WithTeeBuffer(4))
+
+	var wg sync.WaitGroup
+	outs := make([][]interface{}, len(branches))
+	for i, b := range branches {
+		wg.Add(1)
+		go func(i int, b Source) {
+			defer wg.Done()
+			outs[i] = drain(t, b)
+		}(i, b)
+	}
+	wg.Wait()
+
+	for _, out := range outs {
+		r.Equal(in, out)
+	}
+}
+
+func TestTeeBlock(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src := SliceSource([]interface{}{1, 2, 3})
+	branches := Tee(&src, 2)
+
+	v, err := branches[0].Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+
+	// the second branch hasn't read 1 yet, so the first one has to wait
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = branches[0].Next(tctx)
+	r.Error(err)
+
+	// closing the laggard unblocks the other branch
+	r.NoError(branches[1].Close())
+	r.Equal([]interface{}{2, 3}, drain(t, branches[0]))
+
+	_, err = branches[1].Next(ctx)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestTeeDrop(t *testing.T) {
+	r := require.New(t)
+
+	src := SliceSource([]interface{}{1, 2, 3, 4})
+	branches := Tee(&src, 2, WithTeeBuffer(2), WithLagPolicy(LagDrop))
+
+	r.Equal([]interface{}{1, 2, 3, 4}, drain(t, branches[0]))
+	r.Equal([]interface{}{1, 2}, drain(t, branches[1]))
+	r.Equal(uint64(2), branches[1].(DropCounter).Dropped())
+}
+
+func TestTeeError(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	testErr := errors.New("test error")
+	branches := Tee(failingSource(testErr, 1, 2, 3), 2, WithLagPolicy(LagError))
+
+	for _, exp := range []interface{}{1, 2, 3} {
+		v, err := branches[0].Next(ctx)
+		r.NoError(err)
+		r.Equal(exp, v)
+	}
+	_, err := branches[0].Next(ctx)
+	r.Equal(testErr, err)
+
+	v, err := branches[1].Next(ctx)
+	r.NoError(err)
+	r.Equal(1, v)
+	_, err = branches[1].Next(ctx)
+	r.Equal(ErrLagging, err)
+}
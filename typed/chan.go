@@ -0,0 +1,16 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"github.com/ssbc/go-luigi"
+)
+
+// NewPipe returns both ends of a stream of values of type T. It accepts the
+// same options as luigi.NewPipe.
+func NewPipe[T any](opts ...luigi.PipeOpt) (Source[T], Sink[T]) {
+	src, sink := luigi.NewPipe(opts...)
+	return FromSource[T](src), FromSink[T](sink)
+}
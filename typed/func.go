@@ -0,0 +1,38 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"context"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// FuncSink defines a function which can be used as a Sink[T].
+type FuncSink[T any] func(ctx context.Context, v T, err error) error
+
+// Pour implements the Sink interface.
+func (fSink FuncSink[T]) Pour(ctx context.Context, v T) error {
+	return fSink(ctx, v, nil)
+}
+
+// Close implements the Sink interface.
+func (fSink FuncSink[T]) Close() error {
+	var zero T
+	return fSink(nil, zero, luigi.EOS{})
+}
+
+func (fSink FuncSink[T]) CloseWithError(err error) error {
+	var zero T
+	return fSink(nil, zero, err)
+}
+
+// FuncSource defines a function which can be used as a Source[T].
+type FuncSource[T any] func(context.Context) (T, error)
+
+// Next implements the Source interface.
+func (fSrc FuncSource[T]) Next(ctx context.Context) (T, error) {
+	return fSrc(ctx)
+}
@@ -0,0 +1,128 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"github.com/ssbc/go-luigi"
+)
+
+// Broadcast is an interface for registering one or more Sink[T]s to recieve
+// updates.
+type Broadcast[T any] interface {
+	// Register a Sink for updates to be sent.
+	Register(dst Sink[T]) func()
+}
+
+// NewBroadcast returns the Sink, to write to the broadcaster, and the new
+// broadcast instance.
+func NewBroadcast[T any](opts ...luigi.BroadcastOpt) (Sink[T], Broadcast[T]) {
+	sink, bcst := luigi.NewBroadcast(opts...)
+	return FromSink[T](sink), FromBroadcast[T](bcst)
+}
+
+// FromBroadcast returns a Broadcast[T] registering sinks on the untyped bcst.
+func FromBroadcast[T any](bcst luigi.Broadcast) Broadcast[T] {
+	return typedBroadcast[T]{bcst}
+}
+
+type typedBroadcast[T any] struct {
+	bcst luigi.Broadcast
+}
+
+// Register implements the Broadcast interface.
+func (bcst typedBroadcast[T]) Register(dst Sink[T]) func() {
+	return bcst.bcst.Register(ToSink(dst))
+}
+
+// Observable wraps a value of type T and allows tracking changes to it.
+type Observable[T any] interface {
+	// Broadcast allows subscribing to changes
+	Broadcast[T]
+
+	// Set sets a new value
+	Set(T) error
+
+	// Value returns the current value
+	Value() (T, error)
+}
+
+// NewObservable returns a new Observable holding v.
+func NewObservable[T any](v T) Observable[T] {
+	return FromObservable[T](luigi.NewObservable(v))
+}
+
+// FromObservable returns an Observable[T] backed by the untyped obv. If obv
+// was returned by ToObservable, the original typed observable is returned.
+func FromObservable[T any](obv luigi.Observable) Observable[T] {
+	if u, ok := obv.(untypedObservable[T]); ok {
+		return u.obv
+	}
+
+	return typedObservable[T]{obv}
+}
+
+// ToObservable returns an untyped luigi.Observable backed by obv. If obv was
+// returned by FromObservable, the original untyped observable is returned.
+func ToObservable[T any](obv Observable[T]) luigi.Observable {
+	if t, ok := obv.(typedObservable[T]); ok {
+		return t.obv
+	}
+
+	return untypedObservable[T]{obv}
+}
+
+type typedObservable[T any] struct {
+	obv luigi.Observable
+}
+
+// Register implements the Broadcast interface.
+func (obv typedObservable[T]) Register(dst Sink[T]) func() {
+	return obv.obv.Register(ToSink(dst))
+}
+
+// Set implements the Observable interface.
+func (obv typedObservable[T]) Set(v T) error {
+	return obv.obv.Set(v)
+}
+
+// Value implements the Observable interface.
+func (obv typedObservable[T]) Value() (T, error) {
+	v, err := obv.obv.Value()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return Convert[T](v)
+}
+
+type untypedObservable[T any] struct {
+	obv Observable[T]
+}
+
+// Register implements the luigi.Broadcast interface.
+func (obv untypedObservable[T]) Register(dst luigi.Sink) func() {
+	return obv.obv.Register(FromSink[T](dst))
+}
+
+// Set implements the luigi.Observable interface.
+func (obv untypedObservable[T]) Set(v interface{}) error {
+	t, err := Convert[T](v)
+	if err != nil {
+		return err
+	}
+
+	return obv.obv.Set(t)
+}
+
+// Value implements the luigi.Observable interface.
+func (obv untypedObservable[T]) Value() (interface{}, error) {
+	v, err := obv.obv.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
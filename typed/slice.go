@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"context"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// SliceSource binds Source methods to a slice.
+type SliceSource[T any] []T
+
+// Next implements the Source interface.
+func (src *SliceSource[T]) Next(context.Context) (v T, err error) {
+	if len(*src) == 0 {
+		return v, luigi.EOS{}
+	}
+
+	v, *src = (*src)[0], (*src)[1:]
+
+	return v, nil
+}
+
+// SliceSink binds Sink methods to a slice.
+type SliceSink[T any] struct {
+	slice  *[]T
+	closed bool
+}
+
+// NewSliceSink returns a new SliceSink bound to the given slice.
+func NewSliceSink[T any](arg *[]T) *SliceSink[T] {
+	return &SliceSink[T]{
+		slice: arg,
+	}
+}
+
+// Pour implements the Sink interface.  It appends the value to the slice.
+func (sink *SliceSink[T]) Pour(ctx context.Context, v T) error {
+	if sink.closed {
+		return luigi.ErrPourToClosedSink
+	}
+	*sink.slice = append(*sink.slice, v)
+	return nil
+}
+
+// Close implements the Sink interface.
+func (sink *SliceSink[T]) Close() error {
+	sink.closed = true
+	return nil
+}
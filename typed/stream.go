@@ -0,0 +1,190 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+// Package typed provides type-parameterized variants of the luigi stream
+// interfaces, together with adapters to and from the untyped interfaces of
+// the luigi package.
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/ssbc/go-luigi"
+)
+
+// Sink is the interface which wraps methods writing values of type T to a
+// stream.
+type Sink[T any] interface {
+	Pour(ctx context.Context, v T) error
+	Close() error
+}
+
+// Source is the interface which wraps the Next method for reading values of
+// type T from a stream.
+type Source[T any] interface {
+	Next(context.Context) (obj T, err error)
+}
+
+// Pump moves values from a source into a sink.
+//
+// It uses luigi.Pump under the hood, so the same caveats apply.
+func Pump[T any](ctx context.Context, dst Sink[T], src Source[T]) error {
+	return luigi.Pump(ctx, ToSink(dst), ToSource(src))
+}
+
+// TypeError is returned when an untyped stream carries a value that is not of
+// the type expected by the typed side.
+type TypeError struct {
+	Expected reflect.Type
+	Got      interface{}
+}
+
+func (err TypeError) Error() string {
+	return fmt.Sprintf("luigi: expected value of type %v, got %T", err.Expected, err.Got)
+}
+
+// Convert asserts that v is of type T and returns a TypeError if it is not.
+// A nil v is converted to the zero value of T.
+func Convert[T any](v interface{}) (T, error) {
+	var zero T
+	if v == nil {
+		return zero, nil
+	}
+
+	t, ok := v.(T)
+	if !ok {
+		return zero, TypeError{
+			Expected: reflect.TypeOf((*T)(nil)).Elem(),
+			Got:      v,
+		}
+	}
+
+	return t, nil
+}
+
+// FromSource returns a Source[T] that reads from the untyped src and asserts
+// that each value is of type T. If src was returned by ToSource, the original
+// typed source is returned.
+func FromSource[T any](src luigi.Source) Source[T] {
+	if u, ok := src.(untypedSource[T]); ok {
+		return u.src
+	}
+
+	return typedSource[T]{src}
+}
+
+// ToSource returns an untyped luigi.Source reading from src. If src was
+// returned by FromSource, the original untyped source is returned.
+func ToSource[T any](src Source[T]) luigi.Source {
+	if t, ok := src.(typedSource[T]); ok {
+		return t.src
+	}
+
+	return untypedSource[T]{src}
+}
+
+// FromSink returns a Sink[T] that writes to the untyped sink. If sink was
+// returned by ToSink, the original typed sink is returned.
+func FromSink[T any](sink luigi.Sink) Sink[T] {
+	if u, ok := sink.(untypedSink[T]); ok {
+		return u.sink
+	}
+
+	return typedSink[T]{sink}
+}
+
+// ToSink returns an untyped luigi.Sink writing to sink. Values poured into the
+// returned sink that are not of type T are rejected with a TypeError. If sink
+// was returned by FromSink, the original untyped sink is returned.
+func ToSink[T any](sink Sink[T]) luigi.Sink {
+	if t, ok := sink.(typedSink[T]); ok {
+		return t.sink
+	}
+
+	return untypedSink[T]{sink}
+}
+
+type typedSource[T any] struct {
+	src luigi.Source
+}
+
+// Next implements the Source interface.
+func (src typedSource[T]) Next(ctx context.Context) (T, error) {
+	v, err := src.src.Next(ctx)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return Convert[T](v)
+}
+
+type untypedSource[T any] struct {
+	src Source[T]
+}
+
+// Next implements the luigi.Source interface.
+func (src untypedSource[T]) Next(ctx context.Context) (interface{}, error) {
+	v, err := src.src.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+type typedSink[T any] struct {
+	sink luigi.Sink
+}
+
+// Pour implements the Sink interface.
+func (sink typedSink[T]) Pour(ctx context.Context, v T) error {
+	return sink.sink.Pour(ctx, v)
+}
+
+// Close implements the Sink interface.
+func (sink typedSink[T]) Close() error {
+	return sink.sink.Close()
+}
+
+// CloseWithError implements the luigi.ErrorCloser interface. If the wrapped
+// sink doesn't implement it, it is closed using Close.
+func (sink typedSink[T]) CloseWithError(err error) error {
+	return closeWithError(sink.sink, err)
+}
+
+type untypedSink[T any] struct {
+	sink Sink[T]
+}
+
+// Pour implements the luigi.Sink interface.
+func (sink untypedSink[T]) Pour(ctx context.Context, v interface{}) error {
+	t, err := Convert[T](v)
+	if err != nil {
+		return err
+	}
+
+	return sink.sink.Pour(ctx, t)
+}
+
+// Close implements the luigi.Sink interface.
+func (sink untypedSink[T]) Close() error {
+	return sink.sink.Close()
+}
+
+// CloseWithError implements the luigi.ErrorCloser interface. If the wrapped
+// sink doesn't implement it, it is closed using Close.
+func (sink untypedSink[T]) CloseWithError(err error) error {
+	return closeWithError(sink.sink, err)
+}
+
+func closeWithError(sink interface{ Close() error }, err error) error {
+	if ec, ok := sink.(luigi.ErrorCloser); ok {
+		return ec.CloseWithError(err)
+	}
+
+	return sink.Close()
+}
@@ -0,0 +1,134 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package typed // import "github.com/ssbc/go-luigi/typed"
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ssbc/go-luigi"
+	"github.com/stretchr/testify/require"
+)
+
+func ExamplePump() {
+	words := SliceSource[string]{"hello", "typed", "world"}
+
+	var out []string
+	sink := NewSliceSink(&out)
+
+	_ = Pump[string](context.Background(), sink, &words)
+	fmt.Println(out)
+	// Output: [hello typed world]
+}
+
+func TestPipe(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	src, sink := NewPipe[int](luigi.WithBuffer(3))
+
+	for i := 0; i < 3; i++ {
+		r.NoError(sink.Pour(ctx, i), "pouring %d", i)
+	}
+	r.NoError(sink.Close())
+
+	for i := 0; i < 3; i++ {
+		v, err := src.Next(ctx)
+		r.NoError(err, "reading %d", i)
+		r.Equal(i, v)
+	}
+
+	v, err := src.Next(ctx)
+	r.True(luigi.IsEOS(err), "expected end of stream, got %v", err)
+	r.Equal(0, v)
+}
+
+func TestAdapters(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	untypedSrc, untypedSink := luigi.NewPipe(luigi.WithBuffer(2))
+
+	// converting back and forth returns the original value
+	src := FromSource[string](untypedSrc)
+	r.Equal(untypedSrc, ToSource(src))
+	sink := FromSink[string](untypedSink)
+	r.Equal(untypedSink, ToSink(sink))
+
+	var slice SliceSource[string]
+	r.Equal(Source[string](&slice), FromSource[string](ToSource[string](&slice)))
+
+	// values of the wrong type are reported
+	r.NoError(untypedSink.Pour(ctx, 42))
+	_, err := src.Next(ctx)
+	var tErr TypeError
+	r.True(errors.As(err, &tErr), "expected a TypeError, got %v", err)
+
+	err = ToSink[string](NewSliceSink(new([]string))).Pour(ctx, 42)
+	r.True(errors.As(err, &tErr), "expected a TypeError, got %v", err)
+
+	// nil is converted to the zero value
+	r.NoError(untypedSink.Pour(ctx, nil))
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal("", v)
+
+	// closing with an error is passed through
+	testErr := errors.New("test error")
+	r.NoError(sink.(luigi.ErrorCloser).CloseWithError(testErr))
+	_, err = src.Next(ctx)
+	r.Equal(testErr, err)
+}
+
+func TestObservable(t *testing.T) {
+	r := require.New(t)
+
+	obv := NewObservable(1)
+
+	v, err := obv.Value()
+	r.NoError(err)
+	r.Equal(1, v)
+
+	vals := make(chan int, 2)
+	cancel := obv.Register(FuncSink[int](func(ctx context.Context, v int, err error) error {
+		if err == nil {
+			vals <- v
+		}
+		return nil
+	}))
+	defer cancel()
+
+	r.Equal(1, <-vals)
+
+	r.NoError(obv.Set(2))
+	r.Equal(2, <-vals)
+
+	v, err = obv.Value()
+	r.NoError(err)
+	r.Equal(2, v)
+
+	err = ToObservable(obv).Set("two")
+	var tErr TypeError
+	r.True(errors.As(err, &tErr), "expected a TypeError, got %v", err)
+}
+
+func TestConvert(t *testing.T) {
+	r := require.New(t)
+
+	v, err := Convert[int](3)
+	r.NoError(err)
+	r.Equal(3, v)
+
+	v, err = Convert[int](nil)
+	r.NoError(err)
+	r.Equal(0, v, "nil should be converted to the zero value")
+
+	_, err = Convert[int]("3")
+	var tErr TypeError
+	r.True(errors.As(err, &tErr), "expected a TypeError, got %v", err)
+	r.Equal("3", tErr.Got)
+}
@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"sync"
+)
+
+// Zip returns a Source that emits a []interface{} holding the next value of
+// every input, once all of them have one. It ends as soon as one input ends.
+func Zip(srcs ...Source) Source {
+	return &zip{srcs: srcs}
+}
+
+type zip struct {
+	l    sync.Mutex
+	srcs []Source
+
+	// pending holds the values of an incomplete tuple, so they are not lost
+	// when a call to Next is aborted
+	pending []interface{}
+}
+
+// Next implements the Source interface.
+func (src *zip) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	if len(src.srcs) == 0 {
+		return nil, EOS{}
+	}
+
+	for len(src.pending) < len(src.srcs) {
+		v, err := src.srcs[len(src.pending)].Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		src.pending = append(src.pending, v)
+	}
+
+	tuple := src.pending
+	src.pending = nil
+
+	return tuple, nil
+}
+
+// CombineLatest returns a Source that emits a []interface{} holding the
+// latest value of every input whenever one of them emits a value. Nothing is
+// emitted until every input has emitted at least once. It ends once all
+// inputs have ended, and fails like Merge.
+//
+// The inputs are read in the background until they end, so use
+// CombineLatestWithOpts and WithMergeContext to stop reading if the returned
+// source is not read to the end. Use ObservableSource to combine Observables.
+func CombineLatest(srcs ...Source) Source {
+	return CombineLatestWithOpts(srcs)
+}
+
+// CombineLatestWithOpts is like CombineLatest, but accepts the options of
+// MergeWithOpts.
+func CombineLatestWithOpts(srcs []Source, opts ...MergeOpt) Source {
+	indexed := make([]Source, len(srcs))
+	for i, src := range srcs {
+		indexed[i] = indexSource(i, src)
+	}
+
+	return &combineLatest{
+		src:    MergeWithOpts(indexed, opts...),
+		latest: make([]interface{}, len(srcs)),
+		seen:   make([]bool, len(srcs)),
+	}
+}
+
+type indexedValue struct {
+	i int
+	v interface{}
+}
+
+func indexSource(i int, src Source) Source {
+	return FuncSource(func(ctx context.Context) (interface{}, error) {
+		v, err := src.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return indexedValue{i, v}, nil
+	})
+}
+
+type combineLatest struct {
+	l      sync.Mutex
+	src    Source
+	latest []interface{}
+	seen   []bool
+	nSeen  int
+}
+
+// Next implements the Source interface.
+func (src *combineLatest) Next(ctx context.Context) (interface{}, error) {
+	src.l.Lock()
+	defer src.l.Unlock()
+
+	for {
+		v, err := src.src.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		iv := v.(indexedValue)
+		src.latest[iv.i] = iv.v
+		if !src.seen[iv.i] {
+			src.seen[iv.i] = true
+			src.nSeen++
+		}
+
+		if src.nSeen == len(src.latest) {
+			return append([]interface{}(nil), src.latest...), nil
+		}
+	}
+}
+
+// ObservableSource returns a Source that emits the current value of obv and
+// then its later changes. Only the latest value is buffered, so changes made
+// while the source is not read are skipped. Calling the returned function
+// unregisters it, after which the source ends.
+func ObservableSource(obv Observable) (Source, func()) {
+	src, sink := NewPipe(WithBuffer(1), WithOverflow(OverflowDropOldest))
+	return src, obv.Register(sink)
+}
@@ -0,0 +1,166 @@
+// SPDX-FileCopyrightText: 2021 The Luigi Authors
+//
+// SPDX-License-Identifier: MIT
+
+package luigi // import "github.com/ssbc/go-luigi"
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestZip(t *testing.T) {
+	r := require.New(t)
+
+	ids := SliceSource([]interface{}{1, 2, 3})
+	replies := SliceSource([]interface{}{"a", "b"})
+
+	r.Equal([]interface{}{
+		[]interface{}{1, "a"},
+		[]interface{}{2, "b"},
+	}, drain(t, Zip(&ids, &replies)))
+
+	_, err := Zip().Next(context.Background())
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestZipKeepsPending(t *testing.T) {
+	r := require.New(t)
+
+	a := SliceSource([]interface{}{1})
+	b, bSink := NewPipe(WithBuffer(1))
+	src := Zip(&a, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := src.Next(ctx)
+	r.Error(err)
+
+	r.NoError(bSink.Pour(context.Background(), "b"))
+	v, err := src.Next(context.Background())
+	r.NoError(err)
+	r.Equal([]interface{}{1, "b"}, v)
+}
+
+func TestCombineLatest(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	a, aSink := NewPipe()
+	b, bSink := NewPipe()
+	src := CombineLatest(a, b)
+
+	next := func() interface{} {
+		v, err := src.Next(ctx)
+		r.NoError(err)
+		return v
+	}
+
+	go func() {
+		aSink.Pour(ctx, 1)
+		bSink.Pour(ctx, "x")
+	}()
+	r.Equal([]interface{}{1, "x"}, next())
+
+	go aSink.Pour(ctx, 2)
+	r.Equal([]interface{}{2, "x"}, next())
+
+	go aSink.Pour(ctx, 3)
+	r.Equal([]interface{}{3, "x"}, next())
+
+	go bSink.Pour(ctx, "y")
+	r.Equal([]interface{}{3, "y"}, next())
+
+	aSink.Close()
+	bSink.Close()
+	_, err := src.Next(ctx)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}
+
+func TestCombineLatestError(t *testing.T) {
+	r := require.New(t)
+
+	testErr := errors.New("test error")
+	live, _ := NewPipe()
+
+	_, err := CombineLatest(failingSource(testErr, 1), live).Next(context.Background())
+	r.Equal(testErr, err)
+}
+
+func TestCombineLatestObservables(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	width, height := NewObservable(2), NewObservable(3)
+
+	wSrc, wCancel := ObservableSource(width)
+	hSrc, hCancel := ObservableSource(height)
+
+	area := NewObservable(nil)
+	done := make(chan error)
+	go func() {
+		done <- Pump(ctx, FuncSink(func(ctx context.Context, v interface{}, err error) error {
+			if err != nil {
+				return nil
+			}
+			vs := v.([]interface{})
+			return area.Set(vs[0].(int) * vs[1].(int))
+		}), CombineLatest(wSrc, hSrc))
+	}()
+
+	areas, aCancel := ObservableSource(area)
+	defer aCancel()
+
+	waitValue := func(exp interface{}) {
+		for {
+			v, err := areas.Next(ctx)
+			r.NoError(err)
+			if v == exp {
+				return
+			}
+		}
+	}
+
+	waitValue(6)
+	r.NoError(width.Set(5))
+	waitValue(15)
+
+	wCancel()
+	hCancel()
+	r.NoError(<-done)
+}
+
+func TestCombineLatestContext(t *testing.T) {
+	r := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a, _ := NewPipe()
+	b, _ := NewPipe()
+	src := CombineLatestWithOpts([]Source{a, b}, WithMergeContext(ctx))
+
+	cancel()
+	_, err := src.Next(context.Background())
+	r.True(errors.Is(err, context.Canceled), "expected the context's error, got %v", err)
+}
+
+func TestObservableSourceLatest(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	obv := NewObservable(0)
+	src, cancel := ObservableSource(obv)
+	for i := 1; i <= 100; i++ {
+		r.NoError(obv.Set(i))
+	}
+
+	v, err := src.Next(ctx)
+	r.NoError(err)
+	r.Equal(100, v, "only the latest value should be buffered")
+
+	cancel()
+	_, err = src.Next(ctx)
+	r.True(IsEOS(err), "expected end of stream, got %v", err)
+}